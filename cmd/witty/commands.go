@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// version is the version of witty, set at build time with -ldflags "-X main.version=...".
+var version = ""
+
+// command is a command of witty.
+type command struct {
+	name string
+	// sub is the word following the name of commands grouped under a common name, as in "engine query".
+	sub string
+	// usage is the synopsis of the arguments of the command, and summary what it does.
+	usage   string
+	summary string
+	// words are completed as the arguments of the command.
+	words []string
+	// setup defines the flags of the command and returns the function running it with the arguments left
+	// after them. It returns the process exit status: 2 for usage errors.
+	setup func(flags *flag.FlagSet) func(args []string) int
+}
+
+// commands are the commands of witty, set in init as the help and completion commands refer to them.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "run", usage: "[options] [-- shell args]", summary: "run the shell with suggestions (default)", setup: runCommand},
+		{name: "login", usage: "[--engine <engine>]", summary: "log in to an engine, or again if already logged in", setup: loginCommand},
+		{name: "logout", usage: "[--engine <engine>]", summary: "remove the credentials of an engine, or of all of them", setup: logoutCommand},
+		{name: "config", usage: "list | show <name> | rm <name> | edit <name>", summary: "manage the stored configuration",
+			words: []string{"list", "show", "rm", "edit"}, setup: configCommand},
+		{name: "suggest", usage: "[--engine <engine>] [-n <count>] [--format text|json] [prompt]", summary: "print the suggestions of an engine for a prompt read from the arguments or stdin", setup: suggestCommand},
+		{name: "stats", usage: "[--since <duration>]", summary: "summarize the suggestion events logged when analytics are enabled", setup: statsCommand},
+		{name: "engine", sub: "query", usage: "[--engine <engine>] [--program <name>] <prompt>", summary: "query an engine directly and print its response", setup: engineCommand},
+		{name: "replay", usage: "[--engine <engine>]... <recording.cast>", summary: "evaluate engines on the suggestion points of a recording", setup: replayCommand},
+		{name: "doctor", usage: "[--engine <engine>]... [--shell <shell>]", summary: "check the terminal, the configuration, the engines and the shell integration", setup: doctorCommand},
+		{name: "daemon", usage: "[--socket <path>]", summary: "answer the requests of the shell widgets on a Unix socket", setup: daemonCommand},
+		{name: "widget", usage: "[--socket <path>] zsh | bash", summary: "print the shell widget showing the suggestions of the daemon",
+			words: []string{"zsh", "bash"}, setup: widgetCommand},
+		{name: "trust", usage: "[--yes] [path]", summary: "trust the project configuration file of a directory, the current one by default", setup: trustCommand},
+		{name: "version", summary: "print the version of witty", setup: versionCommand},
+		{name: "completion", usage: "bash | zsh | fish", summary: "print the shell completion script",
+			words: []string{"bash", "zsh", "fish"}, setup: completionCommand},
+		{name: "help", usage: "[command]", summary: "show the help of witty or of a command", setup: helpCommand},
+	}
+}
+
+// lookupCommand returns the command with the given name.
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// runMain runs the command selected by the arguments, run if none is, and returns the process exit status.
+func runMain(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-h", "-help", "--help":
+			printUsage(os.Stdout)
+			return 0
+		}
+		if c, ok := lookupCommand(args[0]); ok {
+			return c.execute(args[1:])
+		}
+	}
+	c, _ := lookupCommand("run")
+	return c.execute(args)
+}
+
+// execute parses the flags of the command and runs it.
+func (c command) execute(args []string) int {
+	if c.sub != "" {
+		if len(args) == 0 || args[0] != c.sub {
+			fmt.Fprintf(os.Stderr, "Usage: %s %s %s %s\n", os.Args[0], c.name, c.sub, c.usage)
+			return 2
+		}
+		args = args[1:]
+	}
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	run := c.setup(flags)
+	// Usage is printed below: on stdout when asked for, on stderr after errors.
+	flags.Usage = func() {}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			c.printUsage(os.Stdout, flags)
+			return 0
+		}
+		c.printUsage(os.Stderr, flags)
+		return 2
+	}
+	return run(flags.Args())
+}
+
+// printUsage prints the synopsis and the options of the command.
+func (c command) printUsage(w io.Writer, flags *flag.FlagSet) {
+	name := c.name
+	if c.sub != "" {
+		name += " " + c.sub
+	}
+	fmt.Fprintf(w, "Usage: %s %s %s\n\n%s.\n", os.Args[0], name, c.usage, strings.ToUpper(c.summary[:1])+c.summary[1:])
+	printOptions(w, flags)
+}
+
+// printUsage prints the commands of witty and the options of the default command.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [options]\n\nCommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		name := c.name
+		if c.sub != "" {
+			name += " " + c.sub
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", name, c.summary)
+	}
+	tw.Flush()
+	run, _ := lookupCommand("run")
+	flags := flag.NewFlagSet(run.name, flag.ContinueOnError)
+	run.setup(flags)
+	printOptions(w, flags)
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the options of a command.\n", os.Args[0])
+}
+
+// printOptions prints the flags of a command, with their single letter aliases.
+func printOptions(w io.Writer, flags *flag.FlagSet) {
+	aliases := map[flag.Value]string{}
+	hasOptions := false
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" {
+			aliases[f.Value] = f.Name
+		} else {
+			hasOptions = true
+		}
+	})
+	if !hasOptions {
+		return
+	}
+	fmt.Fprintln(w, "\nOptions:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" {
+			return
+		}
+		placeholder, usage := flag.UnquoteUsage(f)
+		names := "    --" + f.Name
+		if alias, ok := aliases[f.Value]; ok {
+			names = "-" + alias + ", --" + f.Name
+		}
+		if placeholder != "" {
+			names += " <" + placeholder + ">"
+		}
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "0s" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", names, usage)
+	})
+	tw.Flush()
+}
+
+// alias defines a single letter alias of a flag.
+func alias(flags *flag.FlagSet, short, name string) {
+	flags.Var(flags.Lookup(name).Value, short, "")
+}
+
+// choiceValue is a string flag accepting one of a set of values.
+type choiceValue struct {
+	value   *string
+	choices []string
+}
+
+func (v *choiceValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v *choiceValue) Set(s string) error {
+	for _, choice := range v.choices {
+		if s == choice {
+			*v.value = s
+			return nil
+		}
+	}
+	return fmt.Errorf("choose between %s", strings.Join(v.choices, ", "))
+}
+
+// colorValue is a flag holding a color name.
+type colorValue struct {
+	color *tcell.Color
+}
+
+func (v *colorValue) String() string {
+	if v.color == nil || *v.color == tcell.ColorDefault {
+		return ""
+	}
+	for name, color := range tcell.ColorNames {
+		if color == *v.color {
+			return name
+		}
+	}
+	return ""
+}
+
+func (v *colorValue) Set(s string) error {
+	color, ok := tcell.ColorNames[strings.ToLower(s)]
+	if !ok {
+		return fmt.Errorf("invalid color %s", s)
+	}
+	*v.color = color
+	return nil
+}
+
+// engineFlag defines the flag selecting an engine.
+func engineFlag(flags *flag.FlagSet, value *string, usage string) {
+	flags.Var(&choiceValue{value, engineChoices}, "engine", usage)
+}
+
+func versionCommand(flags *flag.FlagSet) func(args []string) int {
+	return func(args []string) int {
+		v := version
+		if info, ok := debug.ReadBuildInfo(); ok && v == "" {
+			v = info.Main.Version
+		}
+		if v == "" {
+			v = "(devel)"
+		}
+		fmt.Printf("witty %s\n", v)
+		return 0
+	}
+}
+
+func helpCommand(flags *flag.FlagSet) func(args []string) int {
+	return func(args []string) int {
+		if len(args) == 0 {
+			printUsage(os.Stdout)
+			return 0
+		}
+		c, ok := lookupCommand(args[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown command %s\n", args[0])
+			return 2
+		}
+		flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(flags)
+		c.printUsage(os.Stdout, flags)
+		return 0
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// runCaptured runs witty with the given arguments and returns its exit status and output.
+func runCaptured(t *testing.T, args ...string) (int, string, string) {
+	stdout, err := ioutil.TempFile(t.TempDir(), "stdout")
+	assert.Nil(t, err)
+	stderr, err := ioutil.TempFile(t.TempDir(), "stderr")
+	assert.Nil(t, err)
+	savedArgs, savedStdout, savedStderr := os.Args, os.Stdout, os.Stderr
+	os.Args, os.Stdout, os.Stderr = append([]string{"witty"}, args...), stdout, stderr
+	code := runMain(args)
+	os.Args, os.Stdout, os.Stderr = savedArgs, savedStdout, savedStderr
+
+	out, err := ioutil.ReadFile(stdout.Name())
+	assert.Nil(t, err)
+	errOut, err := ioutil.ReadFile(stderr.Name())
+	assert.Nil(t, err)
+	stdout.Close()
+	stderr.Close()
+	return code, string(out), string(errOut)
+}
+
+func TestRunMain(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "help", args: []string{"--help"}, stdout: "Commands:"},
+		{name: "command help", args: []string{"help", "widget"}, stdout: "Usage: witty widget"},
+		{name: "command help flag", args: []string{"widget", "-h"}, stdout: "--socket <path>"},
+		{name: "unknown help", args: []string{"help", "bogus"}, code: 2, stderr: "unknown command bogus"},
+		{name: "version", args: []string{"version"}, stdout: "witty "},
+		{name: "unknown command", args: []string{"bogus"}, code: 2, stderr: "unknown command bogus"},
+		{name: "unknown flag", args: []string{"widget", "--bogus", "bash"}, code: 2, stderr: "Usage: witty widget"},
+		{name: "unknown run flag", args: []string{"--bogus"}, code: 2, stderr: "Usage: witty run"},
+		{name: "flag value", args: []string{"widget", "--socket", "/tmp/w.sock", "bash"}, stdout: "_witty_socket='/tmp/w.sock'"},
+		{name: "flag=value", args: []string{"widget", "--socket=/tmp/w.sock", "bash"}, stdout: "_witty_socket='/tmp/w.sock'"},
+		{name: "invalid choice", args: []string{"suggest", "--format=xml", "ls"}, code: 2, stderr: "choose between text, json"},
+		{name: "invalid count", args: []string{"suggest", "-n", "0", "ls"}, code: 2, stderr: "at least 1"},
+		{name: "args after --", args: []string{"widget", "--", "bash"}, stdout: "_witty_socket="},
+		{name: "flag after --", args: []string{"widget", "--", "--socket"}, code: 2, stderr: "no widget for --socket"},
+		{name: "missing subcommand", args: []string{"engine", "--engine", "gpt3.5"}, code: 2, stderr: "Usage: witty engine query"},
+		{name: "missing argument", args: []string{"completion"}, code: 2, stderr: "Usage: witty completion"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, stdout, stderr := runCaptured(t, test.args...)
+			assert.Equal(t, test.code, code)
+			assert.Contains(t, stdout, test.stdout)
+			assert.Contains(t, stderr, test.stderr)
+		})
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	for _, shell := range []string{"bash", "zsh"} {
+		t.Run(shell, func(t *testing.T) {
+			code, script, _ := runCaptured(t, "completion", shell)
+			assert.Equal(t, 0, code)
+			path := filepath.Join(t.TempDir(), "completion")
+			assert.Nil(t, ioutil.WriteFile(path, []byte(script), 0o600))
+			output, err := exec.Command(bash, "-n", path).CombinedOutput()
+			assert.Nil(t, err, string(output))
+		})
+	}
+
+	// The bash script completes the words of commands and the values of their flags.
+	_, script, _ := runCaptured(t, "completion", "bash")
+	path := filepath.Join(t.TempDir(), "completion.bash")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(script), 0o600))
+	complete := func(words ...string) []string {
+		line := "source " + path + "; COMP_WORDS=(" + strings.Join(words, " ") + "); COMP_CWORD=" +
+			strconv.Itoa(len(words)-1) + "; _witty; echo \"${COMPREPLY[*]}\""
+		output, err := exec.Command(bash, "--norc", "-c", line).Output()
+		assert.Nil(t, err)
+		return strings.Fields(string(output))
+	}
+	assert.Equal(t, []string{"zsh", "bash", "--socket"}, complete("witty", "widget", "''"))
+	assert.Equal(t, []string{"text", "json"}, complete("witty", "suggest", "--format", "''"))
+	assert.Equal(t, []string{"config", "completion"}, complete("witty", "co"))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// completedFlag is a flag of a command as completed by shells.
+type completedFlag struct {
+	name  string
+	short string
+	usage string
+	// value is set for flags taking a value, and values lists the values completed for it. Files are
+	// completed for values that are not listed.
+	value  bool
+	values []string
+}
+
+// completedFlags returns the flags of the command.
+func completedFlags(c command) []completedFlag {
+	flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.setup(flags)
+	aliases := map[flag.Value]string{}
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" {
+			aliases[f.Value] = f.Name
+		}
+	})
+	var completed []completedFlag
+	flags.VisitAll(func(f *flag.Flag) {
+		if f.Usage == "" {
+			return
+		}
+		_, usage := flag.UnquoteUsage(f)
+		cf := completedFlag{name: f.Name, short: aliases[f.Value], usage: usage}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			cf.value = true
+		}
+		switch v := f.Value.(type) {
+		case *choiceValue:
+			cf.values = v.choices
+		case *engineNames:
+			cf.values = engineChoices
+		case *colorValue:
+			cf.values = colorNames()
+		}
+		completed = append(completed, cf)
+	})
+	return completed
+}
+
+func colorNames() []string {
+	names := make([]string, 0, len(tcell.ColorNames))
+	for name := range tcell.ColorNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionCommand prints the completion script of a shell.
+func completionCommand(flags *flag.FlagSet) func(args []string) int {
+	return func(args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Usage: %s completion bash | zsh | fish\n", os.Args[0])
+			return 2
+		}
+		switch args[0] {
+		case "bash":
+			fmt.Print(bashCompletion())
+		case "zsh":
+			fmt.Print("autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion())
+		case "fish":
+			fmt.Print(fishCompletion())
+		default:
+			fmt.Fprintf(os.Stderr, "no completion for %s: choose between bash, zsh or fish\n", args[0])
+			return 2
+		}
+		return 0
+	}
+}
+
+// flagWords returns the flags of the command as completed on the command line.
+func flagWords(flags []completedFlag) []string {
+	var words []string
+	for _, f := range flags {
+		words = append(words, "--"+f.name)
+		if f.short != "" {
+			words = append(words, "-"+f.short)
+		}
+	}
+	return words
+}
+
+// commandWords returns the words completed after the name of the command.
+func commandWords(c command) []string {
+	words := c.words
+	switch {
+	case c.sub != "":
+		words = []string{c.sub}
+	case c.name == "help":
+		words = nil
+		for _, other := range commands {
+			words = append(words, other.name)
+		}
+	}
+	return append(append([]string{}, words...), flagWords(completedFlags(c))...)
+}
+
+// bashCompletion returns the completion script of bash, which zsh loads too.
+func bashCompletion() string {
+	var b strings.Builder
+	b.WriteString("# witty completion. Load it with: source <(witty completion bash)\n")
+	b.WriteString("_witty() {\n")
+	b.WriteString("\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} words\n")
+
+	// Values of flags.
+	b.WriteString("\tcase $prev in\n")
+	seen := map[string]bool{}
+	for _, c := range commands {
+		for _, f := range completedFlags(c) {
+			if !f.value || seen[f.name] {
+				continue
+			}
+			seen[f.name] = true
+			pattern := "--" + f.name
+			if f.short != "" {
+				pattern = "-" + f.short + "|" + pattern
+			}
+			if f.values != nil {
+				fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", pattern, strings.Join(f.values, " "))
+			} else {
+				fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", pattern)
+			}
+		}
+	}
+	b.WriteString("\tesac\n")
+
+	// The command is the first word; without one, the words are options of run.
+	run, _ := lookupCommand("run")
+	var first []string
+	for _, c := range commands {
+		first = append(first, c.name)
+	}
+	first = append(first, flagWords(completedFlags(run))...)
+	b.WriteString("\tif [[ $COMP_CWORD -eq 1 ]]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(first, " "))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tcase ${COMP_WORDS[1]} in\n")
+	for _, c := range commands {
+		pattern := c.name
+		if c.name == "run" {
+			pattern = "run|-*"
+		}
+		fmt.Fprintf(&b, "\t%s) words=%q ;;\n", pattern, strings.Join(commandWords(c), " "))
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\t[[ \" ${COMP_WORDS[*]} \" == *\" -- \"* ]] && return\n")
+	b.WriteString("\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -o default -F _witty witty\n")
+	return b.String()
+}
+
+// fishCompletion returns the completion script of fish.
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString("# witty completion. Load it with: witty completion fish | source\n")
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+	}
+	for _, c := range commands {
+		fmt.Fprintf(&b, "complete -c witty -n __fish_use_subcommand -a %s -d %s\n", c.name, quote(c.summary))
+	}
+	for _, c := range commands {
+		conditions := []string{"'__fish_seen_subcommand_from " + c.name + "'"}
+		if c.name == "run" {
+			// Options without a command are options of run.
+			conditions = append(conditions, "__fish_use_subcommand")
+		}
+		words := commandWords(c)
+		var arguments []string
+		for _, word := range words {
+			if !strings.HasPrefix(word, "-") {
+				arguments = append(arguments, word)
+			}
+		}
+		for _, condition := range conditions {
+			if len(arguments) > 0 {
+				fmt.Fprintf(&b, "complete -c witty -n %s -f -a %s\n", condition, quote(strings.Join(arguments, " ")))
+			}
+			for _, f := range completedFlags(c) {
+				fmt.Fprintf(&b, "complete -c witty -n %s -l %s", condition, f.name)
+				if f.short != "" {
+					fmt.Fprintf(&b, " -s %s", f.short)
+				}
+				if f.value {
+					b.WriteString(" -r")
+				}
+				if f.values != nil {
+					fmt.Fprintf(&b, " -f -a %s", quote(strings.Join(f.values, " ")))
+				}
+				fmt.Fprintf(&b, " -d %s\n", quote(f.usage))
+			}
+		}
+	}
+	return b.String()
+}
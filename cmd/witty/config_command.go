@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+
+	"github.com/jjviana/codex/pkg/config"
+)
+
+// sensitiveKey matches the keys of configuration values that are masked when shown.
+var sensitiveKey = regexp.MustCompile(`(?i)key|token|secret|password`)
+
+// errUnchanged aborts storing a configuration that was not changed.
+var errUnchanged = errors.New("unchanged")
+
+// configCommand defines the options of the config command, which has none.
+func configCommand(flags *flag.FlagSet) func(args []string) int {
+	return runConfigCommand
+}
+
+// runConfigCommand implements the commands inspecting and changing the stored configuration and returns
+// the process exit status.
+func runConfigCommand(args []string) int {
+	usage := func() int {
+		fmt.Fprintf(os.Stderr, "Usage: %s config list | show <name> | rm <name> | edit <name>\n", os.Args[0])
+		return 2
+	}
+	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
+		return usage()
+	}
+
+	configDir := configDirectory()
+	repo := config.NewRepository(configDir)
+	secretRepo, _, err := loadConfiguration(configDir, "")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "list":
+		err = listConfigs(repo, secretRepo)
+	case "show":
+		err = showConfig(repo, secretRepo, args[1])
+	case "rm":
+		err = secretRepo.Delete(args[1])
+		if err == nil {
+			fmt.Printf("Removed %s\n", args[1])
+		}
+	case "edit":
+		err = editConfig(repo, args[1])
+	default:
+		return usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func isSecret(name string) bool {
+	for _, secret := range secretNames {
+		if secret == name {
+			return true
+		}
+	}
+	return false
+}
+
+// listConfigs prints the names of the stored configurations, then of the stored secrets. Secrets are not
+// decrypted, so that listing them asks for no passphrase.
+func listConfigs(repo *config.Repository, secretRepo configExister) error {
+	names, err := repo.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	for _, name := range secretNames {
+		exists, err := secretRepo.Exists(name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			fmt.Printf("%s (secret)\n", name)
+		}
+	}
+	return nil
+}
+
+type configExister interface {
+	Exists(name string) (bool, error)
+}
+
+type configLoader interface {
+	Load(name string, config interface{}) error
+}
+
+func showConfig(repo *config.Repository, secretRepo configLoader, name string) error {
+	var value interface{}
+	var err error
+	if isSecret(name) {
+		err = secretRepo.Load(name, &value)
+	} else {
+		err = repo.Load(name, &value)
+	}
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no configuration named %s", name)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(mask(value, isSecret(name) && !isObject(value)), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func isObject(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok
+}
+
+// mask replaces sensitive strings of a configuration value: the values of keys that look like credentials,
+// or all strings if sensitive is set.
+func mask(value interface{}, sensitive bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		masked := map[string]interface{}{}
+		for key, item := range v {
+			masked[key] = mask(item, sensitive || sensitiveKey.MatchString(key))
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = mask(item, sensitive)
+		}
+		return masked
+	case string:
+		if !sensitive || v == "" {
+			return v
+		}
+		if len(v) < 12 {
+			return "****"
+		}
+		return "****" + v[len(v)-4:]
+	default:
+		return v
+	}
+}
+
+// editConfig opens the configuration with the given name in the editor and stores it if it was changed.
+func editConfig(repo *config.Repository, name string) error {
+	if isSecret(name) {
+		return fmt.Errorf("%s holds credentials and cannot be edited; remove it and log in again", name)
+	}
+	content := json.RawMessage("{}")
+	err := repo.Update(name, &content, func() error {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, content, "", "  "); err != nil {
+			return err
+		}
+		edited, err := editText(name, indented.Bytes())
+		if err != nil {
+			return err
+		}
+		if !json.Valid(edited) {
+			return fmt.Errorf("%s is not valid JSON, changes discarded", name)
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, edited); err != nil {
+			return err
+		}
+		if bytes.Equal(compacted.Bytes(), content) {
+			return errUnchanged
+		}
+		content = compacted.Bytes()
+		return nil
+	})
+	if err == errUnchanged {
+		return nil
+	}
+	return err
+}
+
+// editText lets the user edit text in $VISUAL or $EDITOR.
+func editText(name string, text []byte) ([]byte, error) {
+	f, err := ioutil.TempFile("", "witty-"+name+"-*.json")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(append(text, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may come with arguments, as in "code --wait".
+	cmd := exec.Command("/bin/sh", "-c", editor+` "$1"`, "editor", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor failed: %w", err)
+	}
+	return ioutil.ReadFile(f.Name())
+}
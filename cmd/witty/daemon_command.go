@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// The daemon protocol is made for shell scripts. A request is made of fields ended by a NUL byte: the
+// working directory, the input line, the number of history entries and the entries, oldest first. The reply
+// is a single line with the text completing the input line, empty when there is none.
+
+// maxHistory is the number of history entries of a request kept in the prompt.
+const maxHistory = 50
+
+// requestTimeout is how long the daemon waits for a request and the client for the reply.
+const requestTimeout = 10 * time.Second
+
+// socketPath returns the default path of the daemon socket.
+func socketPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".witty", "daemon.sock")
+}
+
+// daemonCommand defines the options of the daemon command.
+func daemonCommand(flags *flag.FlagSet) func(args []string) int {
+	socket := flags.String("socket", socketPath(), "`path` of the Unix socket")
+	connect := flags.Bool("connect", false, "send the request read from stdin to the daemon and print its reply, for shells that cannot open Unix sockets")
+	debug := flags.Bool("debug", false, "write debug logs to stderr")
+	profile := flags.String("profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Usage: %s daemon [options]\n", os.Args[0])
+			return 2
+		}
+		if *debug {
+			zerolog.SetGlobalLevel(zerolog.DebugLevel)
+			log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+		} else {
+			zerolog.SetGlobalLevel(zerolog.Disabled)
+		}
+		if *connect {
+			return connectDaemon(*socket)
+		}
+		return runDaemon(*socket, *profile)
+	}
+}
+
+// daemon answers the requests of the shell widgets.
+type daemon struct {
+	mu        sync.Mutex
+	suggester *suggester
+	cache     *suggestionCache
+}
+
+// runDaemon listens on the socket until interrupted and returns the process exit status.
+func runDaemon(socket, profile string) int {
+	configDir := configDirectory()
+	s, err := newSuggester(configDir, profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	d := &daemon{suggester: s, cache: newSuggestionCache(256)}
+
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		fmt.Fprintf(os.Stderr, "a daemon is already listening on %s\n", socket)
+		return 1
+	}
+	// The socket of a daemon that did not stop properly is left behind.
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.Chmod(socket, 0o600); err != nil {
+		listener.Close()
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	// Changes of the configuration apply to the next requests.
+	stop := config.Watch(configDir, configSections, 2*time.Second, func() {
+		s, err := newSuggester(configDir, profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to reload the configuration: %s\n", err)
+			return
+		}
+		d.mu.Lock()
+		d.suggester, d.cache = s, newSuggestionCache(256)
+		d.mu.Unlock()
+	})
+	defer stop()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "listening on %s\n", socket)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Closing the listener removes the socket.
+			if errors.Is(err, net.ErrClosed) {
+				return 0
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		go d.serve(conn)
+	}
+}
+
+// serve answers the request of a connection.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	// An engine failing on one request must not stop the daemon for every shell.
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "failed to suggest: %v\n", r)
+		}
+	}()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	r := bufio.NewReader(conn)
+	dir, buffer, history, err := readRequest(r)
+	if err != nil {
+		log.Debug().Msgf("invalid request: %v", err)
+		return
+	}
+	// Widgets close the connection when the input line changes before the reply: the request is then skipped
+	// if it is closed before the engine is asked.
+	closed := make(chan struct{})
+	go func() {
+		_, _ = r.ReadByte()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		return
+	default:
+	}
+	text, err := d.suggest(dir, buffer, history)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to suggest: %s\n", err)
+	}
+	_, _ = io.WriteString(conn, text+"\n")
+}
+
+// suggest returns the text completing the input line, from the cache or from the engine. d.mu is only held
+// to look up the cache and the suggester, so that requests of other shells are answered meanwhile.
+func (d *daemon) suggest(dir, buffer string, history []string) (string, error) {
+	if strings.TrimSpace(buffer) == "" {
+		return "", nil
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	var prompt strings.Builder
+	for _, entry := range history {
+		prompt.WriteString("$ " + entry + "\n")
+	}
+	context := dir + "\x00" + prompt.String()
+	d.mu.Lock()
+	cache, suggester := d.cache, d.suggester
+	text, ok := cache.lookup(context, buffer)
+	d.mu.Unlock()
+	if ok {
+		return text, nil
+	}
+	prompt.WriteString("$ " + buffer)
+	_, candidates, err := suggester.suggest(suggestRequest{Prompt: prompt.String(), Dir: dir, Count: 1})
+	if err != nil || len(candidates) == 0 {
+		return "", err
+	}
+	// Widgets display a single line.
+	text = strings.SplitN(candidates[0].Text(), "\n", 2)[0]
+	d.mu.Lock()
+	cache.add(context, buffer, text)
+	d.mu.Unlock()
+	return text, nil
+}
+
+// readRequest reads a request of the daemon protocol.
+func readRequest(r *bufio.Reader) (dir, buffer string, history []string, err error) {
+	field := func() (string, error) {
+		s, err := r.ReadString(0)
+		if err != nil {
+			return "", err
+		}
+		return s[:len(s)-1], nil
+	}
+	if dir, err = field(); err != nil {
+		return
+	}
+	if buffer, err = field(); err != nil {
+		return
+	}
+	count, err := field()
+	if err != nil {
+		return
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return "", "", nil, fmt.Errorf("invalid history length %q", count)
+	}
+	for i := 0; i < n; i++ {
+		entry, err := field()
+		if err != nil {
+			return "", "", nil, err
+		}
+		history = append(history, entry)
+	}
+	return dir, buffer, history, nil
+}
+
+// connectDaemon sends the request read from stdin to the daemon and prints its reply.
+func connectDaemon(socket string) int {
+	request, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "the daemon is not running, start it with witty daemon: %s\n", err)
+		return 1
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	if _, err := conn.Write(request); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Print(reply)
+	return 0
+}
+
+// suggestionCache keeps the last suggestions of the daemon. A suggestion still applies while the input line is
+// extended with the text it suggested: typing it out does not ask the engine again.
+type suggestionCache struct {
+	size    int
+	entries []cacheEntry
+}
+
+type cacheEntry struct {
+	// context is the working directory and the history the suggestion was made with.
+	context string
+	// line is the input line completed by text.
+	line string
+	text string
+}
+
+func newSuggestionCache(size int) *suggestionCache {
+	return &suggestionCache{size: size}
+}
+
+// lookup returns the text completing the line in the given context, if a cached suggestion does.
+func (c *suggestionCache) lookup(context, line string) (string, bool) {
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		e := c.entries[i]
+		if e.context != context || !strings.HasPrefix(line, e.line) {
+			continue
+		}
+		full := e.line + e.text
+		if strings.HasPrefix(full, line) && len(full) > len(line) {
+			return full[len(line):], true
+		}
+	}
+	return "", false
+}
+
+// add records that text completes the line in the given context, evicting the oldest entry when full.
+func (c *suggestionCache) add(context, line, text string) {
+	if text == "" {
+		return
+	}
+	if len(c.entries) == c.size {
+		c.entries = c.entries[1:]
+	}
+	c.entries = append(c.entries, cacheEntry{context, line, text})
+}
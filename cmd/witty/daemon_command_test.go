@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func TestReadRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		dir     string
+		buffer  string
+		history []string
+		err     bool
+	}{
+		{name: "no history", request: "/tmp\x00git st\x000\x00", dir: "/tmp", buffer: "git st"},
+		{name: "history", request: "/home\x00ls\x002\x00cd /home\x00echo 'a\nb'\x00", dir: "/home", buffer: "ls",
+			history: []string{"cd /home", "echo 'a\nb'"}},
+		{name: "empty line", request: "/\x00\x000\x00", dir: "/"},
+		{name: "missing entries", request: "/\x00ls\x002\x00cd /\x00", err: true},
+		{name: "invalid count", request: "/\x00ls\x00two\x00", err: true},
+		{name: "negative count", request: "/\x00ls\x00-1\x00", err: true},
+		{name: "unterminated", request: "/\x00ls", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, buffer, history, err := readRequest(bufio.NewReader(strings.NewReader(test.request)))
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.dir, dir)
+			assert.Equal(t, test.buffer, buffer)
+			assert.Equal(t, test.history, history)
+		})
+	}
+}
+
+func TestSuggestionCache(t *testing.T) {
+	c := newSuggestionCache(2)
+	c.add("a", "git st", "atus")
+	c.add("a", "ls", "")
+
+	tests := []struct {
+		name    string
+		context string
+		line    string
+		text    string
+		found   bool
+	}{
+		{name: "same line", context: "a", line: "git st", text: "atus", found: true},
+		{name: "suggestion typed", context: "a", line: "git sta", text: "tus", found: true},
+		{name: "suggestion typed out", context: "a", line: "git status"},
+		{name: "other text typed", context: "a", line: "git stash"},
+		{name: "line shortened", context: "a", line: "git s"},
+		{name: "other context", context: "b", line: "git st"},
+		{name: "empty suggestions are not kept", context: "a", line: "ls"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			text, found := c.lookup(test.context, test.line)
+			assert.Equal(t, test.found, found)
+			assert.Equal(t, test.text, text)
+		})
+	}
+
+	c.add("a", "cd", " /tmp")
+	c.add("a", "cd /tmp", " && ls")
+	_, found := c.lookup("a", "git st")
+	assert.False(t, found, "the oldest entry is evicted")
+	text, _ := c.lookup("a", "cd /tmp")
+	assert.Equal(t, " && ls", text, "the newest entry wins")
+}
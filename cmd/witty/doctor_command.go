@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/creack/pty"
+	"github.com/gdamore/tcell/v2/terminfo"
+	"github.com/gdamore/tcell/v2/terminfo/dynamic"
+	"github.com/jjviana/codex/pkg/codewhisperer"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/secrets"
+	"github.com/rs/zerolog"
+	"golang.org/x/term"
+)
+
+// doctor prints the outcome of the checks of the doctor command and remembers whether any of them failed.
+type doctor struct {
+	failed bool
+}
+
+func (d *doctor) pass(format string, args ...interface{}) {
+	fmt.Printf("[ok]   %s\n", fmt.Sprintf(format, args...))
+}
+
+// warn reports a problem that degrades witty without preventing it from working.
+func (d *doctor) warn(hint string, format string, args ...interface{}) {
+	fmt.Printf("[warn] %s\n", fmt.Sprintf(format, args...))
+	d.hint(hint)
+}
+
+func (d *doctor) fail(hint string, format string, args ...interface{}) {
+	d.failed = true
+	fmt.Printf("[FAIL] %s\n", fmt.Sprintf(format, args...))
+	d.hint(hint)
+}
+
+func (d *doctor) hint(hint string) {
+	if hint != "" {
+		fmt.Printf("       %s\n", hint)
+	}
+}
+
+// doctorCommand defines the options of the doctor command.
+func doctorCommand(flags *flag.FlagSet) func(args []string) int {
+	var engines engineNames
+	flags.Var(&engines, "engine", "`engine` to check: gpt3.5 or codewhisperer; can be repeated (default: the configured engine and the ones logged in to)")
+	timeout := flags.Duration("timeout", 15*time.Second, "`duration` to wait for the test request of each engine")
+	shell := flags.String("shell", "", "`shell` whose integration to check (default: the configured shell)")
+	profile := flags.String("profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Usage: %s doctor [options]\n", os.Args[0])
+			return 2
+		}
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+		d := &doctor{}
+		d.checkTerminal()
+		d.checkConfiguration(engines, *timeout, *shell, *profile)
+		if d.failed {
+			return 1
+		}
+		return 0
+	}
+}
+
+// checkTerminal checks that witty runs in a terminal it knows how to draw on.
+func (d *doctor) checkTerminal() {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		d.pass("stdin is a terminal")
+	} else {
+		d.fail("Run witty from an interactive terminal, not from a pipe or a script.", "stdin is not a terminal")
+	}
+
+	name := os.Getenv("TERM")
+	if name == "" {
+		d.fail("Set TERM to the type of your terminal, for instance export TERM=xterm-256color.", "TERM is not set")
+		return
+	}
+	if _, err := terminfo.LookupTerminfo(name); err == nil {
+		d.pass("terminal description of TERM=%s is built in", name)
+		return
+	}
+	if _, _, err := dynamic.LoadTerminfo(name); err != nil {
+		d.fail(fmt.Sprintf("Install the terminfo entry of %s (infocmp %s must succeed), or set TERM to a known "+
+			"terminal such as xterm-256color.", name, name), "terminal description of TERM=%s not found: %s", name, err)
+		return
+	}
+	d.pass("terminal description of TERM=%s loaded with infocmp", name)
+}
+
+// checkConfiguration checks the configuration directory and file, then the engines and the shell they select.
+func (d *doctor) checkConfiguration(engines []string, timeout time.Duration, shell, profile string) {
+	configDir := filepath.Join(os.Getenv("HOME"), ".witty")
+	info, err := os.Stat(configDir)
+	switch {
+	case os.IsNotExist(err):
+		d.warn("It is created the first time witty runs.", "configuration directory %s does not exist", configDir)
+	case err != nil:
+		d.fail("", "configuration directory %s: %s", configDir, err)
+		return
+	case !info.IsDir():
+		d.fail(fmt.Sprintf("Move %s out of the way, witty keeps its configuration there.", configDir),
+			"%s is not a directory", configDir)
+		return
+	case info.Mode().Perm()&0o077 != 0:
+		d.fail(fmt.Sprintf("It holds credentials: chmod 700 %s", configDir),
+			"configuration directory %s is accessible to other users (%s)", configDir, info.Mode().Perm())
+	case info.Mode().Perm()&0o700 != 0o700:
+		d.fail(fmt.Sprintf("chmod 700 %s", configDir),
+			"configuration directory %s is not accessible to you (%s)", configDir, info.Mode().Perm())
+		return
+	default:
+		d.pass("configuration directory %s is private", configDir)
+	}
+
+	configRepo, s, err := loadConfiguration(configDir, profile)
+	if err != nil {
+		d.fail("Fix the configuration, or list it with witty config list.", "configuration: %s", err)
+		return
+	}
+	d.pass("configuration loaded")
+
+	if len(engines) == 0 {
+		engines = configuredEngines(s.Engine, configRepo)
+	}
+	if len(engines) == 0 {
+		d.fail("Select an engine with witty login --engine <engine>, or set engine in ~/.witty/config.toml.",
+			"no engine configured")
+	}
+	for _, name := range engines {
+		d.checkEngine(name, configRepo, timeout)
+	}
+
+	if shell == "" {
+		shell = s.Shell
+	}
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	d.checkShell(shell, timeout)
+}
+
+// configuredEngines returns the configured engine and the engines logged in to.
+func configuredEngines(configured string, configRepo configLoader) []string {
+	var engines []string
+	if configured != "" {
+		engines = append(engines, configured)
+	}
+	for _, name := range engineChoices {
+		if name != configured && hasCredentials(name, configRepo) {
+			engines = append(engines, name)
+		}
+	}
+	return engines
+}
+
+// checkEngine checks the credentials of the engine, then sends it a test request.
+func (d *doctor) checkEngine(name string, configRepo *secrets.Repository, timeout time.Duration) {
+	if _, ok := engineSecrets[name]; !ok {
+		d.fail("Choose between "+strings.Join(engineChoices, ", ")+".", "unknown engine %s", name)
+		return
+	}
+	login := fmt.Sprintf("Run witty login --engine %s", name)
+	if !hasCredentials(name, configRepo) {
+		d.fail(login+".", "%s: not logged in", name)
+		return
+	}
+	if name == "codewhisperer" && !d.checkCodeWhispererCredentials(configRepo) {
+		return
+	}
+	d.pass("%s: credentials present", name)
+
+	type result struct {
+		suggestion engine.Suggestion
+		err        error
+	}
+	done := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		e, err := newEngine(name, configRepo)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		suggestion, err := e.Suggest(engine.Request{Prompt: "$ git status\n$ git ", Language: engine.Shell})
+		done <- result{suggestion, err}
+	}()
+	select {
+	case r := <-done:
+		switch {
+		case errors.Is(r.err, engine.ErrUnauthenticated):
+			d.fail(login+" to log in again.", "%s: credentials rejected: %s", name, r.err)
+		case errors.Is(r.err, engine.ErrQuotaExceeded):
+			d.fail("Check the plan and billing of your account.", "%s: %s", name, r.err)
+		case errors.Is(r.err, engine.ErrRateLimited):
+			d.warn("Try again later.", "%s: %s", name, r.err)
+		case r.err != nil:
+			d.fail("Check your network connection and proxy settings.", "%s: test request failed: %s", name, r.err)
+		case r.suggestion == nil:
+			d.pass("%s: answered a test request in %s, without a suggestion", name, time.Since(start).Round(time.Millisecond))
+		default:
+			d.pass("%s: answered a test request in %s", name, time.Since(start).Round(time.Millisecond))
+		}
+	case <-time.After(timeout):
+		d.fail("Check your network connection and proxy settings, or raise --timeout.",
+			"%s: no answer to a test request after %s", name, timeout)
+	}
+}
+
+// checkCodeWhispererCredentials checks the expiry of the CodeWhisperer client registration and token, and
+// reports whether a test request can be sent.
+func (d *doctor) checkCodeWhispererCredentials(configRepo *secrets.Repository) bool {
+	login := "Run witty login --engine codewhisperer"
+	// Without a client registration, creating the engine would register a new client, which the stored token
+	// does not belong to.
+	var client ssooidc.RegisterClientOutput
+	if err := configRepo.Load("codewhisperer-client", &client); err != nil {
+		d.fail(login+".", "codewhisperer: no client registration: %s", err)
+		return false
+	}
+	// The client registration expires after a few months, and tokens can no longer be refreshed with it.
+	if client.ClientSecretExpiresAt != nil {
+		expiry := time.Unix(*client.ClientSecretExpiresAt, 0)
+		if expiry.Before(time.Now()) {
+			d.fail("Run witty config rm codewhisperer-client, then witty login --engine codewhisperer.",
+				"codewhisperer: client registration expired on %s", expiry.Format("2006-01-02"))
+			return false
+		}
+	}
+	var token codewhisperer.Token
+	if err := configRepo.Load("codewhisperer-token", &token); err != nil {
+		d.fail(login+".", "codewhisperer: failed to load the token: %s", err)
+		return false
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		d.warn("The test request refreshes it. "+login+" if it fails.",
+			"codewhisperer: access token expired on %s", token.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	return true
+}
+
+// checkShell starts the shell interactively and checks that its prompt reports the working directory with
+// OSC 7, which project configuration files rely on.
+func (d *doctor) checkShell(shell string, timeout time.Duration) {
+	c := exec.Command(shell, "-i")
+	c.Env = append(os.Environ(), "TERM=xterm-256color")
+	f, err := pty.Start(c)
+	if err != nil {
+		d.fail("Select an installed shell with --shell, or shell in ~/.witty/config.toml.",
+			"failed to start the shell %s: %s", shell, err)
+		return
+	}
+	defer func() {
+		f.Close()
+		_ = c.Process.Kill()
+		_ = c.Wait()
+	}()
+
+	found := make(chan struct{})
+	go func() {
+		var output []byte
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			output = append(output, buf[:n]...)
+			if bytes.Contains(output, []byte("\x1b]7;")) {
+				close(found)
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-found:
+		d.pass("%s reports its working directory (OSC 7)", filepath.Base(shell))
+	case <-time.After(minDuration(timeout, 3*time.Second)):
+		d.warn("Project configuration files follow the directory of the shell process instead. See "+
+			"Shell integration in the Readme to report it from the prompt.",
+			"%s does not report its working directory (OSC 7)", filepath.Base(shell))
+	}
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/jjviana/codex/pkg/codewhisperer"
+	"github.com/jjviana/codex/testing/fakes"
+)
+
+type testDisplay struct{}
+
+func (testDisplay) ShowMessage(string) {}
+
+// captureStdout returns what f prints on stdout.
+func captureStdout(t *testing.T, f func()) string {
+	stdout, err := ioutil.TempFile(t.TempDir(), "stdout")
+	assert.Nil(t, err)
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	f()
+	os.Stdout = saved
+	out, err := ioutil.ReadFile(stdout.Name())
+	assert.Nil(t, err)
+	return string(out)
+}
+
+func TestCheckCodeWhispererCredentials(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+	openAI := fakes.NewOpenAI()
+	defer openAI.Close()
+	configRepo, _, err := loadConfiguration(setupHome(t, openAI, false), "")
+	assert.Nil(t, err)
+	check := func() (bool, string, bool) {
+		d := &doctor{}
+		var ok bool
+		out := captureStdout(t, func() { ok = d.checkCodeWhispererCredentials(configRepo) })
+		return ok, out, d.failed
+	}
+
+	ok, out, failed := check()
+	assert.False(t, ok)
+	assert.True(t, failed)
+	assert.Contains(t, out, "[FAIL] codewhisperer: no client registration")
+
+	// Logging in stores the client registration and the token.
+	_, err = codewhisperer.NewSuggestionEngineWithEndpoints(configRepo, testDisplay{}, codewhisperer.Endpoints{
+		CodeWhisperer: fake.URL,
+		SSOOIDC:       fake.URL,
+	})
+	assert.Nil(t, err)
+	ok, out, failed = check()
+	assert.True(t, ok)
+	assert.False(t, failed)
+	assert.Equal(t, "", out)
+
+	var token codewhisperer.Token
+	assert.Nil(t, configRepo.Load("codewhisperer-token", &token))
+	expired := time.Now().Add(-time.Hour)
+	token.ExpiresAt = &expired
+	assert.Nil(t, configRepo.Store("codewhisperer-token", token))
+	ok, out, failed = check()
+	assert.True(t, ok, "the test request refreshes an expired token")
+	assert.False(t, failed)
+	assert.Contains(t, out, "[warn] codewhisperer: access token expired")
+
+	assert.Nil(t, configRepo.Delete("codewhisperer-token"))
+	ok, out, failed = check()
+	assert.False(t, ok)
+	assert.True(t, failed)
+	assert.Contains(t, out, "[FAIL] codewhisperer: failed to load the token")
+
+	var client ssooidc.RegisterClientOutput
+	assert.Nil(t, configRepo.Load("codewhisperer-client", &client))
+	expiry := time.Now().Add(-time.Hour).Unix()
+	client.ClientSecretExpiresAt = &expiry
+	assert.Nil(t, configRepo.Store("codewhisperer-client", client))
+	ok, out, failed = check()
+	assert.False(t, ok)
+	assert.True(t, failed)
+	assert.Contains(t, out, "[FAIL] codewhisperer: client registration expired")
+}
+
+func TestCheckEngine(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	configRepo, _, err := loadConfiguration(setupHome(t, fake, true), "")
+	assert.Nil(t, err)
+	check := func(name string, timeout time.Duration) (string, bool) {
+		d := &doctor{}
+		out := captureStdout(t, func() { d.checkEngine(name, configRepo, timeout) })
+		return out, d.failed
+	}
+
+	out, failed := check("gpt3.5", 10*time.Second)
+	assert.False(t, failed)
+	assert.Contains(t, out, "[ok]   gpt3.5: answered a test request")
+
+	fake.SetAPIKey("other-key")
+	out, failed = check("gpt3.5", 10*time.Second)
+	assert.True(t, failed)
+	assert.Contains(t, out, "[FAIL] gpt3.5: credentials rejected")
+	fake.SetAPIKey(fakes.OpenAIKey)
+
+	out, failed = check("gpt3.5", time.Nanosecond)
+	assert.True(t, failed)
+	assert.Contains(t, out, "[FAIL] gpt3.5: no answer to a test request after 1ns")
+
+	out, failed = check("codewhisperer", 10*time.Second)
+	assert.True(t, failed)
+	assert.Contains(t, out, "[FAIL] codewhisperer: not logged in")
+
+	// Any failed check makes the command exit with a nonzero status.
+	fake.SetAPIKey("other-key")
+	code, stdout, _ := runCaptured(t, "doctor", "--engine", "gpt3.5", "--shell", "/bin/sh", "--timeout", "1s")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "[FAIL] gpt3.5: credentials rejected")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// engineCommand implements the engine debugging commands.
+func engineCommand(flags *flag.FlagSet) func(args []string) int {
+	engineName := new(string)
+	*engineName = "codewhisperer"
+	engineFlag(flags, engineName, "`engine` to query: gpt3.5 or codewhisperer")
+	program := flags.String("program", "", "foreground `program` whose language the prompt is written in (default: shell)")
+	raw := flags.Bool("raw", true, "print the raw engine responses")
+	debug := flags.Bool("debug", false, "write debug logs to stderr")
+	profile := flags.String("profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		return queryEngine(args, *engineName, *program, *raw, *debug, *profile)
+	}
+}
+
+// queryEngine queries an engine with the given prompt and returns the process exit status.
+func queryEngine(args []string, engineName, program string, raw, debug bool, profile string) int {
+	if debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+	prompt := strings.Join(args, " ")
+	if prompt == "" {
+		fmt.Fprintln(os.Stderr, "a prompt is required")
+		return 2
+	}
+
+	configRepo, _, err := loadConfiguration(configDirectory(), profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	languages, err := loadLanguages(configRepo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load language mappings: %s\n", err)
+		return 1
+	}
+	e, err := newEngine(engineName, configRepo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	request := engine.Request{Prompt: prompt, Language: engine.Shell}
+	if program != "" {
+		request.Language, _ = languages.Lookup(program)
+	}
+	fmt.Printf("Engine: %s, language: %s (%s)\n", engineName, request.Language.Name, request.Language.FileName)
+
+	start := time.Now()
+	suggestion, err := e.Suggest(request)
+	fmt.Printf("Suggest latency: %s\n", time.Since(start))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Suggest failed: %s\n", err)
+		return 1
+	}
+	// Engines may return an empty suggestion, which has no candidates to expand.
+	if suggestion == nil || suggestion.Text() == "" {
+		fmt.Println("No suggestion")
+		return 0
+	}
+	printSuggestion(0, suggestion, raw)
+
+	start = time.Now()
+	candidates, err := e.TopSuggestions(request, suggestion)
+	fmt.Printf("TopSuggestions latency: %s\n", time.Since(start))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "TopSuggestions failed: %s\n", err)
+		return 1
+	}
+	fmt.Printf("Got %d candidates\n", len(candidates))
+	for i, candidate := range candidates {
+		printSuggestion(i+1, candidate, raw)
+	}
+	return 0
+}
+
+func printSuggestion(index int, suggestion engine.Suggestion, raw bool) {
+	if suggestion == nil {
+		fmt.Printf("[%d] <none>\n", index)
+		return
+	}
+	fmt.Printf("[%d] %q\n", index, suggestion.Text())
+	inspectable, ok := suggestion.(engine.Inspectable)
+	if !ok {
+		return
+	}
+	for _, r := range inspectable.References() {
+		fmt.Printf("    reference: %s (%s) %s\n", r.Repository, r.LicenseName, r.URL)
+	}
+	if raw {
+		response, err := json.MarshalIndent(inspectable.RawResponse(), "    ", "  ")
+		if err != nil {
+			fmt.Printf("    raw response: %s\n", err)
+			return
+		}
+		fmt.Printf("    raw response: %s\n", response)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jjviana/codex/pkg/codewhisperer"
+	"github.com/jjviana/codex/pkg/codex"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/prompt"
+	"github.com/jjviana/codex/pkg/secrets"
+	"github.com/jjviana/codex/pkg/transport"
+	"github.com/jjviana/codex/pkg/witty"
+)
+
+// engineChoices are the names of the engines.
+var engineChoices = []string{"gpt3.5", "codewhisperer"}
+
+// newEngine creates the suggestion engine with the given name.
+func newEngine(name string, configRepo *secrets.Repository) (engine.SuggestionEngine, error) {
+	switch name {
+	case "gpt3.5":
+		e, err := codex.NewSuggestionEngine(configRepo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create codex engine: %w", err)
+		}
+		return e, nil
+
+	case "codewhisperer":
+		e, err := codewhisperer.NewSuggestionEngine(configRepo, stdoutDisplay{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create codewhisperer engine: %w", err)
+		}
+		return e, nil
+
+	default:
+		return nil, fmt.Errorf("Invalid engine specified: %s. Choose between gpt3.5 or codewhisperer", name)
+	}
+}
+
+// engineFactory returns the function creating the engines selected by project configuration files. Engines
+// are created while the shell runs and reads the terminal, so engines without credentials are not created:
+// asking for them would compete with the shell for the input.
+func engineFactory(configRepo *secrets.Repository, configDir string) witty.EngineFactory {
+	return func(name string) (engine.SuggestionEngine, *prompt.Builder, error) {
+		if _, ok := engineSecrets[name]; ok && !hasCredentials(name, configRepo) {
+			return nil, nil, engine.NewError(engine.ErrUnauthenticated,
+				fmt.Errorf("not logged in to %s, run witty login --engine %s", name, name))
+		}
+		e, err := newEngine(name, configRepo)
+		if err != nil {
+			return nil, nil, err
+		}
+		builder, err := newPromptBuilder(name, configRepo, configDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		return e, builder, nil
+	}
+}
+
+// newPromptBuilder creates the prompt builder for the engine with the given name. Token counts are exact for
+// OpenAI models and estimated for the other engines.
+func newPromptBuilder(name string, configRepo *secrets.Repository, configDir string) (*prompt.Builder, error) {
+	budget, err := prompt.LoadBudget(configRepo, name)
+	if err != nil {
+		return nil, err
+	}
+	var tokenizer prompt.Tokenizer = prompt.HeuristicTokenizer{}
+	if name == "gpt3.5" {
+		policy, err := transport.LoadPolicy(configRepo)
+		if err != nil {
+			return nil, err
+		}
+		tokenizer = prompt.NewBPETokenizer("gpt-3.5-turbo-instruct", filepath.Join(configDir, "tiktoken"), policy)
+	}
+	return prompt.NewBuilder(tokenizer, budget), nil
+}
+
+// loadLanguages returns the default language mappings merged with the ones the user stored in languages.json.
+func loadLanguages(configRepo *secrets.Repository) (engine.LanguageMap, error) {
+	// Users can map additional programs to languages, or override the defaults, in languages.json
+	languages := engine.LanguageMap{}
+	err := configRepo.Load("languages", &languages)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return engine.DefaultLanguages().Merge(languages), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/secrets"
+)
+
+// engineSecrets are the credentials of each engine. The CodeWhisperer client registration is not tied to
+// the user, so it is kept on logout.
+var engineSecrets = map[string][]string{
+	"gpt3.5":        {"openai-api-key"},
+	"codewhisperer": {"codewhisperer-token"},
+}
+
+// loginCommand defines the options of the login command.
+func loginCommand(flags *flag.FlagSet) func(args []string) int {
+	var engineName, profile string
+	engineFlag(flags, &engineName, "`engine` to log in to (default: the configured engine)")
+	flags.StringVar(&profile, "profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		configRepo, s, err := loadConfiguration(configDirectory(), profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if engineName == "" {
+			engineName = s.Engine
+		}
+		if engineName == "" {
+			fmt.Fprintln(os.Stderr, "no engine configured, select one with --engine")
+			return 2
+		}
+		if err := login(engineName, configRepo); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Logged in to %s\n", engineName)
+		return 0
+	}
+}
+
+// login logs in to the engine. Engines log in when they are created without credentials; with credentials,
+// they are asked to log in again.
+func login(engineName string, configRepo *secrets.Repository) error {
+	loggedIn := hasCredentials(engineName, configRepo)
+	e, err := newEngine(engineName, configRepo)
+	if err != nil {
+		return err
+	}
+	if !loggedIn {
+		return nil
+	}
+	authenticator, ok := e.(engine.Authenticator)
+	if !ok {
+		return fmt.Errorf("%s does not support logging in again", engineName)
+	}
+	return authenticator.Login()
+}
+
+// hasCredentials reports whether the credentials of the engine are stored.
+func hasCredentials(engineName string, configRepo configLoader) bool {
+	for _, name := range engineSecrets[engineName] {
+		var value interface{}
+		if err := configRepo.Load(name, &value); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// logoutCommand defines the options of the logout command.
+func logoutCommand(flags *flag.FlagSet) func(args []string) int {
+	var engineName, profile string
+	engineFlag(flags, &engineName, "`engine` to log out of (default: all engines)")
+	flags.StringVar(&profile, "profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		configRepo, _, err := loadConfiguration(configDirectory(), profile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		engines := engineChoices
+		if engineName != "" {
+			engines = []string{engineName}
+		}
+		for _, name := range engines {
+			if !hasCredentials(name, configRepo) {
+				continue
+			}
+			for _, secret := range engineSecrets[name] {
+				if err := configRepo.Delete(secret); err != nil && !errors.Is(err, secrets.ErrNotFound) {
+					fmt.Fprintf(os.Stderr, "failed to log out of %s: %s\n", name, err)
+					return 1
+				}
+			}
+			fmt.Printf("Logged out of %s\n", name)
+		}
+		return 0
+	}
+}
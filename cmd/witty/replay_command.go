@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jjviana/codex/pkg/asciicast"
+	"github.com/jjviana/codex/pkg/replay"
+	"github.com/jjviana/codex/pkg/scrollback"
+	"github.com/jjviana/codex/pkg/witty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// engineNames collects the values of a repeated flag.
+type engineNames []string
+
+func (n *engineNames) String() string {
+	return strings.Join(*n, ",")
+}
+
+func (n *engineNames) Set(value string) error {
+	*n = append(*n, value)
+	return nil
+}
+
+// replayCommand defines the options of the replay command.
+func replayCommand(flags *flag.FlagSet) func(args []string) int {
+	var engines engineNames
+	flags.Var(&engines, "engine", "`engine` to evaluate: gpt3.5 or codewhisperer; can be repeated")
+	verbose := flags.Bool("verbose", false, "print every suggestion point")
+	debug := flags.Bool("debug", false, "write debug logs to stderr")
+	profile := flags.String("profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Usage: %s replay [--engine <engine>]... [--verbose] <recording.cast>\n", os.Args[0])
+			return 2
+		}
+		return replaySession(args[0], engines, *verbose, *debug, *profile)
+	}
+}
+
+// replaySession replays a recorded session, asks the engines for a suggestion again at every point where
+// one was shown and reports how they compare with what the user typed. It returns the process exit status.
+func replaySession(path string, engines []string, verbose, debug bool, profile string) int {
+	if debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+	} else {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+	}
+
+	configDir := configDirectory()
+	configRepo, _, err := loadConfiguration(configDir, profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	scrollbackConfig, err := scrollback.LoadConfig(configRepo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	languages, err := loadLanguages(configRepo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load language mappings: %s\n", err)
+		return 1
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	points, err := replay.Points(asciicast.NewDecoder(f), func(width, height int) (replay.Terminal, error) {
+		return witty.NewHeadless(width, height, scrollbackConfig)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to replay %s: %s\n", path, err)
+		return 1
+	}
+	fmt.Printf("%d suggestion points\n", len(points))
+
+	results := []replay.Result{replay.Baseline(points)}
+	for _, name := range engines {
+		e, err := newEngine(name, configRepo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		builder, err := newPromptBuilder(name, configRepo, configDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create prompt builder: %s\n", err)
+			return 1
+		}
+		result, suggestions := replay.Evaluate(name, e, builder, languages, points)
+		results = append(results, result)
+		if verbose {
+			for i, p := range points {
+				fmt.Printf("%s %8.3fs typed %q recorded %q suggested %q\n", name, p.Time, p.Typed, p.Recorded, suggestions[i])
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENGINE\tPOINTS\tHIT RATE\tEXACT MATCH\tMEAN EDIT DISTANCE\tEMPTY\tERRORS\tMEDIAN LATENCY")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%.1f%%\t%.1f\t%d\t%d\t%s\n", r.Engine, r.Points, 100*r.HitRate(),
+			100*r.ExactMatchRate(), r.MeanEditDistance(), r.Empty, r.Errors, r.MedianLatency())
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/jjviana/codex/pkg/secrets"
+	"github.com/jjviana/codex/pkg/witty"
+	"golang.org/x/term"
+)
+
+// configSections are the configurations of the repository that can be set in the configuration file.
+var configSections = []string{"transport", "prompt", "scrollback", "trigger", "keymap", "languages", "analytics", "secrets"}
+
+// secretNames are the configurations holding credentials. They are kept in the secret store.
+var secretNames = []string{"openai-api-key", "codewhisperer-token", "codewhisperer-client"}
+
+// settings are the options of witty that can be set in the configuration file and in WITTY_* environment
+// variables. Command line flags override them.
+type settings struct {
+	Engine      string   `json:"engine"`
+	Color       string   `json:"color"`
+	Shell       string   `json:"shell"`
+	ShellArgs   []string `json:"shell_args"`
+	DebugFile   string   `json:"debug_file"`
+	Record      string   `json:"record"`
+	RecordInput bool     `json:"record_input"`
+	// Redact lists regular expressions whose matches are hidden from the engines.
+	Redact []string `json:"redact"`
+}
+
+// loadConfiguration loads the configuration file of the given profile, or of the one selected by the
+// WITTY_PROFILE environment variable, and applies the environment variables over it. Credentials are kept
+// in the secret store.
+func loadConfiguration(configDir, profile string) (*secrets.Repository, settings, error) {
+	var s settings
+	if profile == "" {
+		profile = os.Getenv(config.ProfileEnv)
+	}
+	file, err := config.LoadFile(filepath.Join(configDir, "config.toml"), profile, configSections)
+	if err != nil {
+		return nil, s, err
+	}
+	file.ApplyEnv(os.Environ())
+	if err := file.Settings(&s); err != nil {
+		return nil, s, err
+	}
+	layered := config.NewLayered(config.NewRepository(configDir), file)
+
+	secretsConfig, err := secrets.LoadConfig(layered)
+	if err != nil {
+		return nil, s, err
+	}
+	backend, err := newSecretsBackend(secretsConfig, configDir)
+	if err != nil {
+		return nil, s, err
+	}
+	store := secrets.NewStore(backend, secretsConfig.Sources)
+	return secrets.NewRepository(layered, store, secretNames...), s, nil
+}
+
+// newSecretsBackend creates the backend storing the secrets.
+func newSecretsBackend(c secrets.Config, configDir string) (secrets.Backend, error) {
+	switch c.Backend {
+	case "file", "":
+		secret := secrets.KeyFile(c.KeyFile)
+		if c.KeyFile == "" {
+			secret = secrets.KeyFile(filepath.Join(configDir, "secrets.key"))
+		}
+		if c.Passphrase {
+			secret = readPassphrase
+		}
+		return secrets.NewFileBackend(filepath.Join(configDir, "secrets"), secret), nil
+	case "pass":
+		return secrets.PassBackend{}, nil
+	case "keyring":
+		return secrets.NewKeyringBackend()
+	default:
+		return nil, fmt.Errorf("invalid secrets backend %s. Choose between file, pass or keyring", c.Backend)
+	}
+}
+
+// readPassphrase returns the passphrase of the secret store, from the environment or asked for on the terminal.
+func readPassphrase() ([]byte, error) {
+	if passphrase := os.Getenv(config.PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("the secrets passphrase must be set in %s", config.PassphraseEnv)
+	}
+	fmt.Print("Secrets passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return passphrase, err
+}
+
+// loadOptions loads the configuration and the options of witty that can change while it runs, the command
+// line flags taking precedence over the configuration.
+func loadOptions(flags appConfig, configDir string) (*secrets.Repository, appConfig, witty.Options, error) {
+	c := flags
+	var options witty.Options
+	configRepo, s, err := loadConfiguration(configDir, c.profile)
+	if err != nil {
+		return nil, c, options, err
+	}
+	if err := c.apply(s); err != nil {
+		return nil, c, options, err
+	}
+	options.Color, options.Engine = c.color, c.engine
+	options.Engines = engineFactory(configRepo, configDir)
+	if options.Keymap, err = witty.LoadKeymap(configRepo); err != nil {
+		return nil, c, options, err
+	}
+	if options.TriggerPolicy, err = witty.LoadTriggerPolicy(configRepo); err != nil {
+		return nil, c, options, err
+	}
+	if options.Languages, err = loadLanguages(configRepo); err != nil {
+		return nil, c, options, fmt.Errorf("failed to load language mappings: %w", err)
+	}
+	if options.Redaction, err = project.CompileRules(s.Redact); err != nil {
+		return nil, c, options, err
+	}
+	return configRepo, c, options, nil
+}
+
+// apply sets the options of the application from the settings, unless they were given on the command line.
+func (c *appConfig) apply(s settings) error {
+	if c.engine == "" {
+		c.engine = s.Engine
+	}
+	if c.color == tcell.ColorDefault && s.Color != "" {
+		color, ok := tcell.ColorNames[strings.ToLower(s.Color)]
+		if !ok {
+			return fmt.Errorf("invalid color %s", s.Color)
+		}
+		c.color = color
+	}
+	if c.shell == "" {
+		c.shell = s.Shell
+	}
+	if c.shellArgs == nil {
+		c.shellArgs = s.ShellArgs
+	}
+	if c.debugFile == "" {
+		c.debugFile = s.DebugFile
+	}
+	if c.record == "" {
+		c.record = s.Record
+	}
+	if !c.recordInput {
+		c.recordInput = s.RecordInput
+	}
+	return nil
+}
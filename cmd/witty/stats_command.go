@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jjviana/codex/pkg/analytics"
+)
+
+// analyticsLogPath returns the path of the suggestion event log.
+func analyticsLogPath(configDir string) string {
+	return filepath.Join(configDir, "analytics.jsonl")
+}
+
+// statsCommand defines the options of the stats command.
+func statsCommand(flags *flag.FlagSet) func(args []string) int {
+	since := flags.Duration("since", 0, "only count events more recent than this `duration`, e.g. 168h (default: all events)")
+	return func(args []string) int {
+		return printStats(*since)
+	}
+}
+
+// printStats summarizes the suggestion event log and returns the process exit status.
+func printStats(since time.Duration) int {
+
+	f, err := os.Open(analyticsLogPath(configDirectory()))
+	if os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "No suggestion events logged. Enable analytics with {\"Enabled\": true} in ~/.witty/analytics.json.")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	var from time.Time
+	if since > 0 {
+		from = time.Now().Add(-since)
+	}
+	stats, err := analytics.ReadStats(f, from, time.Local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %s\n", f.Name(), err)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	printCounts(w, "TOTAL", map[string]*analytics.Counts{"all": &stats.Total})
+	printCounts(w, "ENGINE", stats.ByEngine)
+	printCounts(w, "PROGRAM", stats.ByProgram)
+	hours := map[string]*analytics.Counts{}
+	for hour, c := range stats.ByHour {
+		hours[fmt.Sprintf("%02d:00", hour)] = c
+	}
+	printCounts(w, "HOUR", hours)
+	w.Flush()
+	return 0
+}
+
+// printCounts prints a table of the given groups, sorted by name.
+func printCounts(w *tabwriter.Writer, title string, groups map[string]*analytics.Counts) {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(w, "%s\tSHOWN\tACCEPTED\tPARTIAL\tDISMISSED\tACCEPTANCE\tP50 LATENCY\tP95 LATENCY\n", title)
+	for _, name := range names {
+		c := groups[name]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t%s\n", name, c.Shown, c.Accepted, c.PartiallyAccepted,
+			c.Dismissed, 100*c.AcceptanceRate(), c.Percentile(0.5).Round(time.Millisecond), c.Percentile(0.95).Round(time.Millisecond))
+	}
+	fmt.Fprintln(w)
+}
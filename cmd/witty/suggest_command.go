@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/rs/zerolog"
+	"golang.org/x/term"
+)
+
+// Exit statuses of the suggest command besides 0, 1 for errors and 2 for usage errors.
+const (
+	exitNoSuggestion    = 3
+	exitUnauthenticated = 4
+)
+
+// suggestOutput is the JSON output of the suggest command.
+type suggestOutput struct {
+	Engine      string            `json:"engine"`
+	Suggestions []suggestionEntry `json:"suggestions"`
+}
+
+type suggestionEntry struct {
+	Text       string             `json:"text"`
+	References []engine.Reference `json:"references,omitempty"`
+}
+
+// suggestCommand defines the options of the suggest command.
+func suggestCommand(flags *flag.FlagSet) func(args []string) int {
+	var r suggestRequest
+	var format, profile string
+	engineFlag(flags, &r.Engine, "`engine` to ask (default: the one of the project or the configured engine)")
+	alias(flags, "e", "engine")
+	flags.IntVar(&r.Count, "count", 1, "maximum `number` of candidates")
+	alias(flags, "n", "count")
+	format = "text"
+	flags.Var(&choiceValue{&format, []string{"text", "json"}}, "format", "output `format`: text, one candidate per line, or json")
+	flags.StringVar(&r.Program, "program", "", "`program` the prompt is written for, selecting its language (default: shell)")
+	flags.StringVar(&r.Suffix, "suffix", "", "`text` after the cursor")
+	flags.StringVar(&r.Dir, "dir", ".", "`directory` whose project configuration applies")
+	flags.StringVar(&profile, "profile", "", "`profile` of the configuration file to use")
+	return func(args []string) int {
+		zerolog.SetGlobalLevel(zerolog.Disabled)
+		if len(args) > 0 {
+			r.Prompt = strings.Join(args, " ")
+		} else if term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintf(os.Stderr, "Usage: %s suggest [options] <prompt>, or with the prompt on stdin\n", os.Args[0])
+			return 2
+		} else {
+			data, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to read the prompt: %s\n", err)
+				return 1
+			}
+			// Like $(...), drop the newline ending the last line: it would ask for the start of a new command.
+			r.Prompt = strings.TrimSuffix(string(data), "\n")
+		}
+		return suggest(r, format, profile)
+	}
+}
+
+// suggest prints the candidates of an engine for the request and returns the process exit status.
+func suggest(r suggestRequest, format, profile string) int {
+	if r.Count < 1 {
+		fmt.Fprintln(os.Stderr, "the number of candidates must be at least 1")
+		return 2
+	}
+	s, err := newSuggester(configDirectory(), profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	name, candidates, err := s.suggest(r)
+	switch {
+	case errors.Is(err, engine.ErrUnauthenticated):
+		fmt.Fprintln(os.Stderr, err)
+		return exitUnauthenticated
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if format == "json" {
+		output := suggestOutput{Engine: name, Suggestions: []suggestionEntry{}}
+		for _, c := range candidates {
+			entry := suggestionEntry{Text: c.Text()}
+			if inspectable, ok := c.(engine.Inspectable); ok {
+				entry.References = inspectable.References()
+			}
+			output.Suggestions = append(output.Suggestions, entry)
+		}
+		data, err := json.Marshal(output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(data))
+	} else {
+		for _, c := range candidates {
+			fmt.Println(c.Text())
+		}
+	}
+	if len(candidates) == 0 {
+		return exitNoSuggestion
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/codex"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/jjviana/codex/testing/fakes"
+	"github.com/rs/zerolog"
+)
+
+// setupHome points HOME to a new directory whose configuration uses the OpenAI fake, logged in unless
+// loggedIn is false. It returns the configuration directory.
+func setupHome(t *testing.T, fake *fakes.OpenAI, loggedIn bool) string {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	home := t.TempDir()
+	saved := os.Getenv("HOME")
+	t.Cleanup(func() { os.Setenv("HOME", saved) })
+	os.Setenv("HOME", home)
+	configDir := configDirectory()
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(configDir, "config.toml"), []byte("engine = \"gpt3.5\"\n"), 0o600))
+
+	configRepo, _, err := loadConfiguration(configDir, "")
+	assert.Nil(t, err)
+	err = configRepo.Store("OPENAI_COMPLETION_PARAMETERS", codex.CompletionParameters{
+		MaxTokens: 64,
+		Stop:      []string{"\n"},
+		LogProbs:  10,
+		BaseURL:   fake.EnginesURL(),
+	})
+	assert.Nil(t, err)
+	if loggedIn {
+		assert.Nil(t, configRepo.Store("openai-api-key", fakes.OpenAIKey))
+	}
+	return configDir
+}
+
+func TestSuggest(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	setupHome(t, fake, true)
+	dir := t.TempDir()
+
+	// The fake answers the requests for the alternatives with the same text: it is listed once.
+	fake.SetCompletions("git status", "git diff")
+	code, stdout, _ := runCaptured(t, "suggest", "--dir", dir, "-n", "3", "--format", "json", "$ git")
+	assert.Equal(t, 0, code)
+	var output suggestOutput
+	assert.Nil(t, json.Unmarshal([]byte(stdout), &output))
+	assert.Equal(t, suggestOutput{Engine: "gpt3.5", Suggestions: []suggestionEntry{{Text: "git status"}}}, output)
+	assert.Equal(t, 3, len(fake.Requests()))
+	assert.Equal(t, "$ git", fake.Requests()[0].Prompt)
+
+	code, stdout, _ = runCaptured(t, "suggest", "--dir", dir, "$ git")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "git status\n", stdout)
+
+	fake.SetCompletions()
+	code, stdout, _ = runCaptured(t, "suggest", "--dir", dir, "--format", "json", "$ git")
+	assert.Equal(t, exitNoSuggestion, code)
+	assert.Equal(t, "{\"engine\":\"gpt3.5\",\"suggestions\":[]}\n", stdout)
+}
+
+func TestSuggestNotLoggedIn(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	setupHome(t, fake, false)
+
+	code, _, stderr := runCaptured(t, "suggest", "--dir", t.TempDir(), "$ git")
+	assert.Equal(t, exitUnauthenticated, code)
+	assert.Contains(t, stderr, "witty login --engine gpt3.5")
+	assert.Equal(t, 0, len(fake.Requests()))
+}
+
+func TestSuggestProject(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	setupHome(t, fake, true)
+	dir := t.TempDir()
+	content := "preamble = \"A Go project.\"\nredact = ['hunter\\d']\n"
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, project.FileName), []byte(content), 0o600))
+	lastPrompt := func() string {
+		requests := fake.Requests()
+		return requests[len(requests)-1].Prompt
+	}
+
+	// Redaction rules apply right away, the preamble once the file is trusted.
+	code, _, _ := runCaptured(t, "suggest", "--dir", dir, "$ echo hunter2")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "$ echo [REDACTED]", lastPrompt())
+	code, _, _ = runCaptured(t, "trust", "--yes", dir)
+	assert.Equal(t, 0, code)
+	code, _, _ = runCaptured(t, "suggest", "--dir", dir, "$ echo hunter2")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "A Go project.\n$ echo [REDACTED]", lastPrompt())
+
+	off := filepath.Join(dir, "off")
+	assert.Nil(t, os.Mkdir(off, 0o700))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(off, project.FileName), []byte("enabled = false\n"), 0o600))
+	requests := len(fake.Requests())
+	code, _, _ = runCaptured(t, "suggest", "--dir", off, "$ git")
+	assert.Equal(t, exitNoSuggestion, code)
+	assert.Equal(t, requests, len(fake.Requests()), "no request where suggestions are off")
+}
+
+func TestSuggestStdin(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	setupHome(t, fake, true)
+
+	stdin, err := ioutil.TempFile(t.TempDir(), "stdin")
+	assert.Nil(t, err)
+	_, err = stdin.WriteString("$ cd src\n$ git\n")
+	assert.Nil(t, err)
+	_, err = stdin.Seek(0, 0)
+	assert.Nil(t, err)
+	savedStdin := os.Stdin
+	os.Stdin = stdin
+	code, _, _ := runCaptured(t, "suggest", "--dir", t.TempDir())
+	os.Stdin = savedStdin
+	stdin.Close()
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "$ cd src\n$ git", fake.Requests()[0].Prompt, "only the last newline is trimmed")
+}
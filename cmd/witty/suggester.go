@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/jjviana/codex/pkg/prompt"
+	"github.com/jjviana/codex/pkg/secrets"
+)
+
+// suggestRequest is a request for suggestions made outside the terminal emulator.
+type suggestRequest struct {
+	// Engine is the engine to ask, the one of the project or the configured one when empty.
+	Engine string `json:"engine,omitempty"`
+	Prompt string `json:"prompt"`
+	Suffix string `json:"suffix,omitempty"`
+	// Program is the program the prompt is written for, the shell when empty.
+	Program string `json:"program,omitempty"`
+	// Dir is the directory whose project configuration applies, none when empty.
+	Dir string `json:"dir,omitempty"`
+	// Count is the number of candidates wanted, at least one.
+	Count int `json:"count,omitempty"`
+}
+
+// suggester asks the engines for suggestions with the settings of witty: it builds the prompt within the budget
+// of the engine, applies the redaction rules and the project configuration, and keeps the engines it creates.
+type suggester struct {
+	configRepo *secrets.Repository
+	configDir  string
+	engineName string
+	languages  engine.LanguageMap
+	redaction  project.Rules
+	trust      *project.Trust
+
+	mu      sync.Mutex
+	engines map[string]*suggesterEngine
+}
+
+type suggesterEngine struct {
+	// mu serializes the requests to the engine, as engines are not safe for concurrent use. Requests to other
+	// engines are not held up.
+	mu      sync.Mutex
+	engine  engine.SuggestionEngine
+	builder *prompt.Builder
+}
+
+// newSuggester creates a suggester with the configuration of the given profile.
+func newSuggester(configDir, profile string) (*suggester, error) {
+	configRepo, s, err := loadConfiguration(configDir, profile)
+	if err != nil {
+		return nil, err
+	}
+	languages, err := loadLanguages(configRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load language mappings: %w", err)
+	}
+	redaction, err := project.CompileRules(s.Redact)
+	if err != nil {
+		return nil, err
+	}
+	trust, err := project.LoadTrust(config.NewRepository(configDir))
+	if err != nil {
+		return nil, err
+	}
+	return &suggester{
+		configRepo: configRepo,
+		configDir:  configDir,
+		engineName: s.Engine,
+		languages:  languages,
+		redaction:  redaction,
+		trust:      trust,
+		engines:    map[string]*suggesterEngine{},
+	}, nil
+}
+
+// suggest returns the name of the engine asked and up to r.Count candidates, none when the engine had no
+// suggestion or the project turns suggestions off. Engines are not asked to log in: their errors are
+// engine.ErrUnauthenticated until the user runs witty login.
+func (s *suggester) suggest(r suggestRequest) (string, []engine.Suggestion, error) {
+	p := project.Project{Enabled: true}
+	if r.Dir != "" {
+		files, err := project.Lookup(r.Dir)
+		if err != nil {
+			return "", nil, err
+		}
+		// Files cannot be trusted from here: the sensitive settings of the untrusted ones are left out.
+		p = project.Resolve(files, s.trust.Trusted)
+	}
+	name := r.Engine
+	if name == "" {
+		name = p.Engine
+	}
+	if name == "" {
+		name = s.engineName
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("no engine configured, select one with --engine")
+	}
+	if !p.Enabled {
+		return name, nil, nil
+	}
+
+	s.mu.Lock()
+	e, err := s.engine(name)
+	s.mu.Unlock()
+	if err != nil {
+		return name, nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	request := engine.Request{
+		Prompt:   s.redaction.Redact(p.Redact(e.builder.Build(r.Prompt, p.Preamble, r.Suffix))),
+		Suffix:   s.redaction.Redact(p.Redact(r.Suffix)),
+		Language: engine.Shell,
+	}
+	if request.Prompt != "" && p.Preamble != "" {
+		request.Prompt = p.Preamble + "\n" + request.Prompt
+	}
+	if r.Program != "" {
+		request.Language, _ = s.languages.Lookup(r.Program)
+	}
+
+	suggestion, err := e.engine.Suggest(request)
+	if err != nil || suggestion == nil || suggestion.Text() == "" {
+		return name, nil, err
+	}
+	candidates := []engine.Suggestion{suggestion}
+	if r.Count <= 1 {
+		return name, candidates, nil
+	}
+	top, err := e.engine.TopSuggestions(request, suggestion)
+	if err != nil {
+		return name, nil, err
+	}
+	seen := map[string]bool{suggestion.Text(): true}
+	for _, c := range top {
+		if len(candidates) == r.Count {
+			break
+		}
+		if c == nil || c.Text() == "" || seen[c.Text()] {
+			continue
+		}
+		seen[c.Text()] = true
+		candidates = append(candidates, c)
+	}
+	return name, candidates, nil
+}
+
+// engine returns the engine with the given name, creating it the first time. It is called with s.mu held.
+func (s *suggester) engine(name string) (*suggesterEngine, error) {
+	if e, ok := s.engines[name]; ok {
+		return e, nil
+	}
+	if _, ok := engineSecrets[name]; ok && !hasCredentials(name, s.configRepo) {
+		return nil, engine.NewError(engine.ErrUnauthenticated,
+			fmt.Errorf("not logged in to %s, run witty login --engine %s", name, name))
+	}
+	e, err := newEngine(name, s.configRepo)
+	if err != nil {
+		return nil, err
+	}
+	builder, err := newPromptBuilder(name, s.configRepo, s.configDir)
+	if err != nil {
+		return nil, err
+	}
+	s.engines[name] = &suggesterEngine{engine: e, builder: builder}
+	return s.engines[name], nil
+}
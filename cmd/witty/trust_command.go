@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/project"
+)
+
+// trustCommand defines the options of the trust command.
+func trustCommand(flags *flag.FlagSet) func(args []string) int {
+	yes := flags.Bool("yes", false, "trust the file without asking for confirmation")
+	return func(args []string) int {
+		if len(args) > 1 {
+			fmt.Fprintf(os.Stderr, "Usage: %s trust [--yes] [path]\n", os.Args[0])
+			return 2
+		}
+		path := "."
+		if len(args) == 1 {
+			path = args[0]
+		}
+		return trust(path, *yes)
+	}
+}
+
+// trust shows the sensitive settings of a project configuration file, the one of path if it is a directory,
+// and trusts the file once confirmed. It returns the process exit status.
+func trust(path string, yes bool) int {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, project.FileName)
+	}
+	f, err := project.Read(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(f.Sensitive()) == 0 {
+		fmt.Printf("%s has no settings that need trust\n", path)
+		return 0
+	}
+
+	fmt.Printf("%s sets:\n", path)
+	for _, key := range f.Sensitive() {
+		switch key {
+		case "enabled":
+			fmt.Println("  enabled = true")
+		case "engine":
+			fmt.Printf("  engine = %q\n", f.Config.Engine)
+		case "preamble":
+			fmt.Printf("  preamble = %q\n", f.Config.Preamble)
+		}
+	}
+	if !yes {
+		fmt.Print("Trust it? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Not trusted")
+			return 1
+		}
+	}
+
+	t, err := project.LoadTrust(config.NewRepository(configDirectory()))
+	if err == nil {
+		err = t.Allow(f)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Trusted %s\n", path)
+	return 0
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/project"
+)
+
+func TestTrust(t *testing.T) {
+	home := t.TempDir()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	dir := t.TempDir()
+	path := filepath.Join(dir, project.FileName)
+	assert.Nil(t, ioutil.WriteFile(path, []byte("engine = \"codewhisperer\"\n"), 0o600))
+	f, err := project.Read(path)
+	assert.Nil(t, err)
+	trusted := func() bool {
+		trust, err := project.LoadTrust(config.NewRepository(filepath.Join(home, ".witty")))
+		assert.Nil(t, err)
+		return trust.Trusted(f)
+	}
+
+	// Anything but a confirmation leaves the file untrusted.
+	stdin, err := ioutil.TempFile(t.TempDir(), "stdin")
+	assert.Nil(t, err)
+	_, err = stdin.WriteString("\n")
+	assert.Nil(t, err)
+	_, err = stdin.Seek(0, 0)
+	assert.Nil(t, err)
+	savedStdin := os.Stdin
+	os.Stdin = stdin
+	code, stdout, _ := runCaptured(t, "trust", dir)
+	os.Stdin = savedStdin
+	stdin.Close()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `engine = "codewhisperer"`)
+	assert.False(t, trusted())
+
+	code, _, _ = runCaptured(t, "trust", "--yes", dir)
+	assert.Equal(t, 0, code)
+	assert.True(t, trusted())
+}
@@ -0,0 +1,26 @@
+# witty widget for bash, inserts the suggestion of witty daemon at the end of the line. Readline cannot show
+# text it does not edit, so the suggestion is requested with Ctrl-F, which moves forward elsewhere.
+# Load it with: eval "$(witty widget bash)"
+_witty_socket=${_witty_socket:-~/.witty/daemon.sock}
+
+_witty_suggest() {
+	if (( READLINE_POINT < ${#READLINE_LINE} )); then
+		(( READLINE_POINT++ ))
+		return
+	fi
+	[[ -n ${READLINE_LINE//[[:space:]]/} ]] || return
+	local entries=() entry suggestion
+	while IFS= read -r entry; do
+		entry=${entry#"${entry%%[![:space:]]*}"}
+		[[ -n $entry ]] && entries+=("$entry")
+	done < <(fc -ln -20 2>/dev/null)
+	suggestion=$(
+		{
+			printf '%s\0' "$PWD" "$READLINE_LINE" "${#entries[@]}"
+			(( ${#entries[@]} )) && printf '%s\0' "${entries[@]}"
+		} | command witty daemon --connect --socket "$_witty_socket" 2>/dev/null
+	) || return
+	READLINE_LINE+=$suggestion
+	READLINE_POINT=${#READLINE_LINE}
+}
+bind -x '"\C-f": _witty_suggest'
@@ -0,0 +1,43 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed widget.zsh
+var zshWidget string
+
+//go:embed widget.bash
+var bashWidget string
+
+// widgetCommand prints the script of the shell widget talking to the daemon.
+func widgetCommand(flags *flag.FlagSet) func(args []string) int {
+	socket := flags.String("socket", socketPath(), "`path` of the Unix socket of the daemon")
+	return func(args []string) int {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Usage: %s widget [--socket <path>] zsh | bash\n", os.Args[0])
+			return 2
+		}
+		var script string
+		switch args[0] {
+		case "zsh":
+			script = zshWidget
+		case "bash":
+			script = bashWidget
+		default:
+			fmt.Fprintf(os.Stderr, "no widget for %s: choose between zsh or bash\n", args[0])
+			return 2
+		}
+		fmt.Printf("_witty_socket=%s\n%s", shellQuote(*socket), script)
+		return 0
+	}
+}
+
+// shellQuote quotes s as a single word for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
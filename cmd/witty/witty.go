@@ -2,15 +2,19 @@ package main
 
 import (
 	_ "embed"
+	"flag"
 	"fmt"
-	"github.com/jjviana/codex/pkg/codewhisperer"
-	"github.com/jjviana/codex/pkg/config"
-	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/analytics"
+	"github.com/jjviana/codex/pkg/asciicast"
 	"os"
-	"strings"
+	"path/filepath"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
-	"github.com/jjviana/codex/pkg/codex"
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/jjviana/codex/pkg/scrollback"
+	"github.com/jjviana/codex/pkg/secrets"
 	"github.com/jjviana/codex/pkg/witty"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -22,71 +26,40 @@ type appConfig struct {
 	debugFile string
 	shell     string
 	shellArgs []string
+	// record is the file the session is recorded to, in asciicast format.
+	record      string
+	recordInput bool
+	// profile is the profile of the configuration file to use.
+	profile string
 }
 
-func parseArgs() *appConfig {
-	conf := &appConfig{}
-
-	for i := 1; i < len(os.Args); i++ {
-		switch os.Args[i] {
-		case "-c":
-			i++
-			if i < len(os.Args) {
-				var ok bool
-				conf.color, ok = tcell.ColorNames[strings.ToLower(os.Args[i])]
-				if !ok {
-					log.Fatal().Msgf("invalid color %s", os.Args[i])
-					os.Exit(1)
-				}
-			}
-		case "-d":
-			if i+1 < len(os.Args) {
-				conf.debugFile = os.Args[i+1]
-				i++
-			} else {
-				log.Print("-d requires an argument")
-				os.Exit(1)
-			}
-		case "-s":
-			if i+1 < len(os.Args) {
-				conf.shell = os.Args[i+1]
-				i++
-			} else {
-				log.Print("-s requires an argument value")
-				os.Exit(1)
-			}
-		case "-h":
-			printUsage()
-			os.Exit(0)
-		case "--":
-			conf.shellArgs = os.Args[i+1:]
-			break
-
-		case "-e":
-			if i+1 < len(os.Args) {
-				conf.engine = os.Args[i+1]
-				if conf.engine != "gpt3.5" && conf.engine != "codewhisperer" {
-					log.Fatal().Msgf("invalid engine %s", conf.engine)
-					os.Exit(1)
-				}
-				i++
-			} else {
-				log.Print("-e requires an argument value")
-				os.Exit(1)
+// runCommand defines the options of the run command. They can also be set in the configuration file and
+// with WITTY_* environment variables named after them, which the options override.
+func runCommand(flags *flag.FlagSet) func(args []string) int {
+	c := &appConfig{}
+	engineFlag(flags, &c.engine, "completion `engine`: gpt3.5 or codewhisperer")
+	alias(flags, "e", "engine")
+	flags.Var(&colorValue{&c.color}, "color", "`color` of the suggestions")
+	flags.StringVar(&c.debugFile, "debug-file", "", "turn on debug mode and write to `file`")
+	alias(flags, "d", "debug-file")
+	flags.StringVar(&c.shell, "shell", "", "`shell` to run (default $SHELL)")
+	alias(flags, "s", "shell")
+	flags.StringVar(&c.record, "record", "", "record the session to `file` in asciicast v2 format")
+	alias(flags, "r", "record")
+	flags.BoolVar(&c.recordInput, "record-input", false, "also record keyboard input, passwords included")
+	flags.StringVar(&c.profile, "profile", "", "use the settings of a `profile` of ~/.witty/config.toml (default $WITTY_PROFILE)")
+	alias(flags, "p", "profile")
+	return func(args []string) int {
+		if len(args) > 0 {
+			// Arguments only reach the shell after --, which the flag package consumes.
+			if n := len(os.Args) - len(args); n == 0 || os.Args[n-1] != "--" {
+				fmt.Fprintf(os.Stderr, "unknown command %s. Pass arguments to the shell after --\n", args[0])
+				return 2
 			}
+			c.shellArgs = args
 		}
+		return runWitty(*c)
 	}
-	return conf
-}
-
-func printUsage() {
-	log.Printf("Usage: %s [options] [shell args]", os.Args[0])
-	log.Printf("Options:")
-	log.Printf("  -e <engine>: Selects the completion engine. Valid values are: gpt3.5 or codewhisperer")
-	log.Printf("  -d <file>: turn on debug mode and write to file.")
-	log.Printf("  -s shell: select shell to run (default $SHELL)")
-	log.Printf("  --: pass the rest of the args to the shell.")
-	log.Printf("  -h: show help.")
 }
 
 type stdoutDisplay struct {
@@ -97,7 +70,17 @@ func (s stdoutDisplay) ShowMessage(msg string) {
 }
 
 func main() {
-	c := parseArgs()
+	os.Exit(runMain(os.Args[1:]))
+}
+
+// runWitty runs the shell with suggestions and returns the process exit status.
+func runWitty(flags appConfig) int {
+	configDir := configDirectory()
+	configRepo, c, options, err := loadOptions(flags, configDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
 	if c.shell == "" {
 		// Finds the current shell based on the $SHELL environment variable
 		c.shell = os.Getenv("SHELL")
@@ -110,8 +93,8 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 		f, err := os.OpenFile(c.debugFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o666)
 		if err != nil {
-			log.Err(err).Msgf("failed to open debug file %s", c.debugFile)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "failed to open debug file %s: %s\n", c.debugFile, err)
+			return 1
 		}
 		defer f.Close()
 		// Change the zerolog global logger to write to the file.
@@ -121,36 +104,89 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.Disabled)
 	}
 
-	configRepo := config.NewRepository(configDirectory())
+	// The engine may ask for credentials: unlike the engines created later, it can read the terminal.
+	e, err := newEngine(options.Engine, configRepo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	promptBuilder, err := newPromptBuilder(options.Engine, configRepo, configDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
 
-	var e engine.SuggestionEngine
-	var err error
+	scrollbackConfig, err := scrollback.LoadConfig(configRepo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
 
-	switch c.engine {
-	case "gpt3.5":
-		e, err = codex.NewSuggestionEngine(configRepo)
+	w := witty.New(e, options.Color, c.shell, c.shellArgs, options.Languages, promptBuilder, scrollbackConfig)
+	w.SetTriggerPolicy(options.TriggerPolicy)
+	w.SetKeymap(options.Keymap)
+	w.SetRedaction(options.Redaction)
+
+	trust, err := project.LoadTrust(config.NewRepository(configDir))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	w.SetProjects(trust, c.engine, engineFactory(configRepo, configDir))
+
+	analyticsConfig, err := analytics.LoadConfig(configRepo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if analyticsConfig.Enabled {
+		// The key of the suggestion hashes is created once per installation.
+		key, err := secrets.KeyFile(filepath.Join(configDir, "analytics.key"))()
 		if err != nil {
-			fmt.Printf("failed to create codex engine: %s", err)
-			return
+			fmt.Fprintf(os.Stderr, "failed to read the analytics key: %s\n", err)
+			return 1
 		}
-
-	case "codewhisperer":
-		e, err = codewhisperer.NewSuggestionEngine(configRepo, stdoutDisplay{})
+		eventLog, err := analytics.Open(analyticsLogPath(configDir), analyticsConfig, key)
 		if err != nil {
-			fmt.Printf("failed to create codewhisperer engine: %s", err)
-			return
+			fmt.Fprintf(os.Stderr, "failed to open analytics log: %s\n", err)
+			return 1
 		}
+		defer eventLog.Close()
+		w.SetAnalytics(eventLog, c.engine)
+	}
 
-	default:
-		fmt.Printf("Invalid engine specified: %s. Choose between gpt3.5 or codewhisperer\n", c.engine)
-		return
+	if c.record != "" {
+		f, err := os.OpenFile(c.record, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create recording: %s\n", err)
+			return 1
+		}
+		recorder := asciicast.NewRecorder(f, c.recordInput)
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to write recording: %s\n", err)
+			}
+		}()
+		w.SetRecorder(recorder)
 	}
 
-	w := witty.New(e, c.color, c.shell, c.shellArgs)
+	// Changes of the configuration apply without restarting the shell.
+	stop := config.Watch(configDir, configSections, 2*time.Second, func() {
+		_, _, options, err := loadOptions(flags, configDir)
+		if err != nil {
+			w.ReloadFailed(err)
+			return
+		}
+		w.Reload(options)
+	})
+	defer stop()
 
 	if err := w.Run(); err != nil {
 		log.Err(err).Msgf("failed to run : %s", err)
+		fmt.Fprintf(os.Stderr, "failed to run: %s\n", err)
+		return 1
 	}
+	return 0
 }
 
 func configDirectory() string {
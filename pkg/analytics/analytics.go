@@ -0,0 +1,121 @@
+// Package analytics keeps a local log of suggestion events, to measure how useful suggestions are. Nothing
+// is logged unless the user opts in, and nothing leaves the machine.
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	Shown             = "shown"
+	Accepted          = "accepted"
+	PartiallyAccepted = "partially_accepted"
+	Dismissed         = "dismissed"
+)
+
+// Config decides whether and how suggestion events are logged.
+type Config struct {
+	// Enabled turns the event log on.
+	Enabled bool
+	// FullText stores suggestions as text. They are stored as hashes otherwise, which only tells
+	// suggestions apart. Hashes are keyed with a secret of the installation, so that common commands
+	// cannot be found in the log by hashing them.
+	FullText bool
+}
+
+// configName is the name the configuration is stored under in the configuration repository.
+const configName = "analytics"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+}
+
+// LoadConfig loads the configuration from the configuration repository. Logging is disabled unless the
+// configuration says otherwise.
+func LoadConfig(configRepository configRepository) (Config, error) {
+	var config Config
+	err := configRepository.Load(configName, &config)
+	if err != nil && !os.IsNotExist(err) {
+		return config, fmt.Errorf("failed to load analytics configuration: %w", err)
+	}
+	return config, nil
+}
+
+// Event is a line of the event log.
+type Event struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Engine string    `json:"engine"`
+	// Program is the program in the foreground of the terminal when the suggestion was requested.
+	Program string `json:"program,omitempty"`
+	// Latency is the time the engine took to make the suggestion.
+	Latency time.Duration `json:"latency"`
+	// Length is the length of the suggestion and AcceptedLength the length of the part accepted, in bytes.
+	Length         int    `json:"length"`
+	AcceptedLength int    `json:"accepted_length,omitempty"`
+	Hash           string `json:"hash,omitempty"`
+	Text           string `json:"text,omitempty"`
+}
+
+// Log appends events to the event log file. It is safe for concurrent use.
+type Log struct {
+	mu       sync.Mutex
+	f        *os.File
+	fullText bool
+	key      []byte
+}
+
+// Open opens the event log at the given path, creating it if needed. Suggestions are hashed with key, which
+// must be random and kept for the lifetime of the log.
+func Open(path string, config Config, key []byte) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{f: f, fullText: config.FullText, key: key}, nil
+}
+
+// Record logs an event about the given suggestion text, of which accepted bytes were accepted. The time
+// of the event is set if missing.
+func (l *Log) Record(event Event, text string, accepted int) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Length = len(text)
+	event.AcceptedLength = accepted
+	if l.fullText {
+		event.Text = text
+	} else {
+		event.Hash = Hash(l.key, text)
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// A single write per event keeps lines whole when several witty instances share the log.
+	_, err = l.f.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the event log.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Hash returns the hash logged in place of a suggestion text: an HMAC of the text keyed with key.
+func Hash(key []byte, text string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(text))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
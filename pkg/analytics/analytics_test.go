@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "analytics")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "analytics.jsonl")
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	l, err := Open(path, Config{Enabled: true}, key)
+	assert.Nil(t, err)
+	at := time.Date(2023, 5, 1, 9, 30, 0, 0, time.UTC)
+	events := []struct {
+		event    Event
+		accepted int
+	}{
+		{Event{Type: Shown, Engine: "gpt3.5", Program: "bash", Latency: 100 * time.Millisecond}, 0},
+		{Event{Type: Accepted, Engine: "gpt3.5", Program: "bash"}, 9},
+		{Event{Type: Shown, Engine: "gpt3.5", Program: "python3", Latency: 300 * time.Millisecond}, 0},
+		{Event{Type: PartiallyAccepted, Engine: "gpt3.5", Program: "python3"}, 3},
+		{Event{Type: Shown, Engine: "codewhisperer", Program: "bash", Latency: 200 * time.Millisecond}, 0},
+		{Event{Type: Dismissed, Engine: "codewhisperer", Program: "bash"}, 0},
+	}
+	for _, e := range events {
+		e.event.Time = at
+		assert.Nil(t, l.Record(e.event, "git status", e.accepted))
+	}
+	assert.Nil(t, l.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(data), "git status"), "text is hashed unless opted in")
+	assert.True(t, strings.Contains(string(data), Hash(key, "git status")))
+	assert.NotEqual(t, Hash(key, "git status"), Hash([]byte("another key"), "git status"))
+
+	stats, err := ReadStats(strings.NewReader(string(data)), time.Time{}, time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, stats.Total.Shown)
+	assert.Equal(t, 1.0, stats.ByEngine["gpt3.5"].AcceptanceRate())
+	assert.Equal(t, 0.0, stats.ByEngine["codewhisperer"].AcceptanceRate())
+	assert.Equal(t, 2, stats.ByProgram["bash"].Shown)
+	assert.Equal(t, 3, stats.ByHour[9].Shown)
+	assert.Equal(t, 200*time.Millisecond, stats.Total.Percentile(0.5))
+	assert.Equal(t, 300*time.Millisecond, stats.Total.Percentile(0.95))
+
+	stats, err = ReadStats(strings.NewReader(string(data)), at.Add(time.Hour), time.UTC)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, stats.Total.Shown)
+}
@@ -0,0 +1,129 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Counts summarizes the events of a group.
+type Counts struct {
+	Shown             int
+	Accepted          int
+	PartiallyAccepted int
+	Dismissed         int
+	// Latencies are the latencies of the suggestions shown, sorted.
+	Latencies []time.Duration
+}
+
+func (c *Counts) add(event Event) {
+	switch event.Type {
+	case Shown:
+		c.Shown++
+		c.Latencies = append(c.Latencies, event.Latency)
+	case Accepted:
+		c.Accepted++
+	case PartiallyAccepted:
+		c.PartiallyAccepted++
+	case Dismissed:
+		c.Dismissed++
+	}
+}
+
+// AcceptanceRate returns the fraction of the suggestions shown that were accepted, fully or partially.
+func (c Counts) AcceptanceRate() float64 {
+	if c.Shown == 0 {
+		return 0
+	}
+	return float64(c.Accepted+c.PartiallyAccepted) / float64(c.Shown)
+}
+
+// Percentile returns the latency below which the given fraction of the suggestions were made.
+func (c Counts) Percentile(p float64) time.Duration {
+	if len(c.Latencies) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(c.Latencies))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(c.Latencies) {
+		i = len(c.Latencies) - 1
+	}
+	return c.Latencies[i]
+}
+
+// Stats summarizes an event log.
+type Stats struct {
+	Total     Counts
+	ByEngine  map[string]*Counts
+	ByHour    map[int]*Counts
+	ByProgram map[string]*Counts
+}
+
+// ReadStats summarizes the events read from r that happened after since. Hours are in the location loc.
+func ReadStats(r io.Reader, since time.Time, loc *time.Location) (*Stats, error) {
+	stats := &Stats{
+		ByEngine:  map[string]*Counts{},
+		ByHour:    map[int]*Counts{},
+		ByProgram: map[string]*Counts{},
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if event.Time.Before(since) {
+			continue
+		}
+		program := event.Program
+		if program == "" {
+			program = "unknown"
+		}
+		stats.Total.add(event)
+		group(stats.ByEngine, event.Engine).add(event)
+		group(stats.ByProgram, program).add(event)
+		hour := event.Time.In(loc).Hour()
+		if stats.ByHour[hour] == nil {
+			stats.ByHour[hour] = &Counts{}
+		}
+		stats.ByHour[hour].add(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sortLatencies(&stats.Total)
+	for _, groups := range []map[string]*Counts{stats.ByEngine, stats.ByProgram} {
+		for _, c := range groups {
+			sortLatencies(c)
+		}
+	}
+	for _, c := range stats.ByHour {
+		sortLatencies(c)
+	}
+	return stats, nil
+}
+
+func group(groups map[string]*Counts, key string) *Counts {
+	c, ok := groups[key]
+	if !ok {
+		c = &Counts{}
+		groups[key] = c
+	}
+	return c
+}
+
+func sortLatencies(c *Counts) {
+	sort.Slice(c.Latencies, func(i, j int) bool { return c.Latencies[i] < c.Latencies[j] })
+}
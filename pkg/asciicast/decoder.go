@@ -0,0 +1,103 @@
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Event is an event of a recording.
+type Event struct {
+	// Time is the number of seconds since the start of the recording.
+	Time float64
+	Type string
+	Data string
+}
+
+// Decoder reads a recording.
+type Decoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewDecoder creates a decoder reading the recording from r.
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	// Output events of busy sessions can be long.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &Decoder{scanner: scanner}
+}
+
+// Header reads the recording header. It must be called before reading events.
+func (d *Decoder) Header() (Header, error) {
+	var header Header
+	if !d.scan() {
+		return header, d.error(io.ErrUnexpectedEOF)
+	}
+	if err := json.Unmarshal(d.scanner.Bytes(), &header); err != nil {
+		return header, d.error(err)
+	}
+	if header.Version != 2 {
+		return header, d.error(fmt.Errorf("unsupported asciicast version %d", header.Version))
+	}
+	return header, nil
+}
+
+// Next reads the next event. It returns io.EOF at the end of the recording.
+func (d *Decoder) Next() (Event, error) {
+	var event Event
+	if !d.scan() {
+		return event, d.error(io.EOF)
+	}
+	var fields []json.RawMessage
+	if err := json.Unmarshal(d.scanner.Bytes(), &fields); err != nil {
+		return event, d.error(err)
+	}
+	if len(fields) != 3 {
+		return event, d.error(fmt.Errorf("event has %d fields instead of 3", len(fields)))
+	}
+	var err error
+	if event.Time, err = strconv.ParseFloat(string(fields[0]), 64); err != nil {
+		return event, d.error(fmt.Errorf("invalid event time: %w", err))
+	}
+	if err := json.Unmarshal(fields[1], &event.Type); err != nil {
+		return event, d.error(err)
+	}
+	if err := json.Unmarshal(fields[2], &event.Data); err != nil {
+		return event, d.error(err)
+	}
+	return event, nil
+}
+
+// scan reads the next non-empty line.
+func (d *Decoder) scan() bool {
+	for d.scanner.Scan() {
+		d.line++
+		if strings.TrimSpace(d.scanner.Text()) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decoder) error(err error) error {
+	if scanErr := d.scanner.Err(); scanErr != nil {
+		err = scanErr
+	}
+	if err == io.EOF {
+		return err
+	}
+	return fmt.Errorf("line %d: %w", d.line, err)
+}
+
+// ParseSize parses the data of a resize event.
+func ParseSize(data string) (int, int, error) {
+	var width, height int
+	if _, err := fmt.Sscanf(data, "%dx%d", &width, &height); err != nil {
+		return 0, 0, fmt.Errorf("invalid size %q: %w", data, err)
+	}
+	return width, height, nil
+}
@@ -0,0 +1,43 @@
+package asciicast
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+func TestDecoder(t *testing.T) {
+	out := &closeBuffer{}
+	r := NewRecorder(out, true)
+	assert.Nil(t, r.Start(80, 24, nil))
+	r.Output([]byte("$ "))
+	r.Resize(120, 40)
+	r.Marker("suggestion shown: ls")
+	assert.Nil(t, r.Close())
+
+	d := NewDecoder(strings.NewReader(out.String()))
+	header, err := d.Header()
+	assert.Nil(t, err)
+	assert.Equal(t, 80, header.Width)
+
+	var events []Event
+	for {
+		event, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		events = append(events, event)
+	}
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, Event{Time: events[0].Time, Type: Output, Data: "$ "}, events[0])
+	width, height, err := ParseSize(events[1].Data)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{120, 40}, []int{width, height})
+	assert.Equal(t, "suggestion shown: ls", events[2].Data)
+
+	_, err = NewDecoder(strings.NewReader(`{"version": 1}`)).Header()
+	assert.NotNil(t, err)
+}
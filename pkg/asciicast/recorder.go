@@ -0,0 +1,151 @@
+// Package asciicast records terminal sessions in the asciicast v2 format used by asciinema.
+// See https://docs.asciinema.org/manual/asciicast/v2/.
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event types.
+const (
+	Output = "o"
+	Input  = "i"
+	Resize = "r"
+	Marker = "m"
+)
+
+// Header is the first line of a recording.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes a session recording. Events recorded before Start are dropped.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu          sync.Mutex
+	out         io.WriteCloser
+	w           *bufio.Writer
+	start       time.Time
+	started     bool
+	recordInput bool
+	// pending holds the beginning of an UTF-8 sequence split across output chunks.
+	pending []byte
+	err     error
+}
+
+// NewRecorder creates a recorder writing to out. Input events are only recorded if recordInput is set,
+// as they include anything typed, passwords too.
+func NewRecorder(out io.WriteCloser, recordInput bool) *Recorder {
+	return &Recorder{out: out, w: bufio.NewWriter(out), recordInput: recordInput}
+}
+
+// Start writes the recording header for a terminal of the given size. Event times are relative to it.
+func (r *Recorder) Start(width, height int, env map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.start = time.Now()
+	r.started = true
+	header, err := json.Marshal(Header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Env:       env,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.w.Write(append(header, '\n')); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+// Output records data written by the shell to the terminal.
+func (r *Recorder) Output(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// JSON strings must be valid UTF-8: keep an incomplete trailing sequence for the next chunk.
+	data = append(r.pending, data...)
+	end := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[end:]...)
+	if end > 0 {
+		r.write(Output, string(data[:end]))
+	}
+}
+
+// Input records data typed by the user, if input recording is enabled.
+func (r *Recorder) Input(data []byte) {
+	if !r.recordInput {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(Input, string(data))
+}
+
+// Resize records a change of the terminal size.
+func (r *Recorder) Resize(width, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(Resize, fmt.Sprintf("%dx%d", width, height))
+}
+
+// Marker records a marker with the given label. Players show markers as chapters of the recording.
+func (r *Recorder) Marker(label string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.write(Marker, label)
+}
+
+// write writes an event. Events are flushed right away, so that the recording survives a crash.
+// Write errors are kept and returned by Close.
+func (r *Recorder) write(eventType, data string) {
+	if !r.started || r.err != nil {
+		return
+	}
+	elapsed := time.Since(r.start).Seconds()
+	event, err := json.Marshal([]interface{}{json.Number(fmt.Sprintf("%.6f", elapsed)), eventType, data})
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err := r.w.Write(append(event, '\n')); err != nil {
+		r.err = err
+		return
+	}
+	r.err = r.w.Flush()
+}
+
+// Close closes the recording, returning the first error met while writing it.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) > 0 {
+		r.write(Output, string(r.pending))
+	}
+	if err := r.out.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
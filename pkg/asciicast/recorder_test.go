@@ -0,0 +1,65 @@
+package asciicast
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+}
+
+func (b *closeBuffer) Close() error {
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	out := &closeBuffer{}
+	r := NewRecorder(out, false)
+	r.Output([]byte("dropped before start"))
+	assert.Nil(t, r.Start(80, 24, map[string]string{"TERM": "xterm-256color"}))
+
+	euro := []byte("€")
+	r.Output(append([]byte("price: "), euro[:1]...))
+	r.Output(euro[1:])
+	r.Input([]byte("not recorded"))
+	r.Resize(100, 30)
+	r.Marker("suggestion shown: ls -l")
+	assert.Nil(t, r.Close())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 5, len(lines))
+
+	var header Header
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, 2, header.Version)
+	assert.Equal(t, 80, header.Width)
+	assert.Equal(t, "xterm-256color", header.Env["TERM"])
+
+	var events [][]interface{}
+	for _, line := range lines[1:] {
+		var event []interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event[1:])
+	}
+	assert.Equal(t, [][]interface{}{
+		{"o", "price: "},
+		{"o", "€"},
+		{"r", "100x30"},
+		{"m", "suggestion shown: ls -l"},
+	}, events)
+}
+
+func TestRecorderInput(t *testing.T) {
+	out := &closeBuffer{}
+	r := NewRecorder(out, true)
+	assert.Nil(t, r.Start(80, 24, nil))
+	r.Input([]byte("ls\r"))
+	assert.Nil(t, r.Close())
+
+	assert.True(t, strings.HasSuffix(out.String(), `,"i","ls\r"]`+"\n"), out.String())
+}
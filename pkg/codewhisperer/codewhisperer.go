@@ -4,6 +4,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/jjviana/codex/pkg/codewhisperer/service"
 	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/transport"
 	"github.com/rs/zerolog/log"
 	"strings"
 )
@@ -15,8 +16,17 @@ type CodeWhisperer struct {
 
 // NewSuggestionEngine creates a new CodeWhisperer suggestion engine.
 func NewSuggestionEngine(config configRepository, display display) (*CodeWhisperer, error) {
-	sessionManager := NewSessionManager(config, display)
-	err := sessionManager.Start()
+	return NewSuggestionEngineWithEndpoints(config, display, DefaultEndpoints)
+}
+
+// NewSuggestionEngineWithEndpoints creates a new CodeWhisperer suggestion engine talking to the given endpoints.
+func NewSuggestionEngineWithEndpoints(config configRepository, display display, endpoints Endpoints) (*CodeWhisperer, error) {
+	policy, err := transport.LoadPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	sessionManager := NewSessionManager(config, display, endpoints, policy)
+	err = sessionManager.Start()
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +36,11 @@ func NewSuggestionEngine(config configRepository, display display) (*CodeWhisper
 
 }
 
+// Login logs in to CodeWhisperer again with an AWS Builder ID.
+func (c *CodeWhisperer) Login() error {
+	return c.sessionManager.Login()
+}
+
 type codeWhispererSuggestion struct {
 	prompt          string
 	completion      *service.GenerateCompletionsOutput
@@ -47,41 +62,68 @@ func (s *codeWhispererSuggestion) Text() string {
 	return ""
 }
 
-const fileName = "script.sh"
-const languageName = "shell"
+// References returns the code references CodeWhisperer attached to the suggestion.
+func (s *codeWhispererSuggestion) References() []engine.Reference {
+	if len(s.completion.Completions) <= s.completionIndex {
+		return nil
+	}
+	var references []engine.Reference
+	for _, r := range s.completion.Completions[s.completionIndex].References {
+		references = append(references, engine.Reference{
+			LicenseName: aws.StringValue(r.LicenseName),
+			Repository:  aws.StringValue(r.Repository),
+			URL:         aws.StringValue(r.Url),
+		})
+	}
+	return references
+}
+
+// RawResponse returns the GenerateCompletions response the suggestion was taken from.
+func (s *codeWhispererSuggestion) RawResponse() interface{} {
+	return s.completion
+}
+
+// fileContext builds the CodeWhisperer file context for the given request.
+func fileContext(request engine.Request) *service.FileContext {
+	language := request.Language
+	if language.Name == "" {
+		language = engine.Shell
+	}
+	return &service.FileContext{
+		Filename:         aws.String(language.FileName),
+		LeftFileContent:  aws.String(request.Prompt),
+		RightFileContent: aws.String(request.Suffix),
+		ProgrammingLanguage: &service.ProgrammingLanguage{
+			LanguageName: aws.String(language.Name),
+		},
+	}
+}
 
-// Suggest returns a suggestion for the given prompt.
-func (c *CodeWhisperer) Suggest(prompt string) (engine.Suggestion, error) {
+// Suggest returns a suggestion for the given request.
+func (c *CodeWhisperer) Suggest(request engine.Request) (engine.Suggestion, error) {
 
-	log.Debug().Msgf("Fetching suggestions with CodeWhisperer")
+	log.Debug().Msgf("Fetching %s suggestions with CodeWhisperer", request.Language.Name)
 
 	// Call the CodeWhisperer recommendation completion api.
 	result, err := c.sessionManager.GenerateCompletions(&service.GenerateCompletionsInput{
-		FileContext: &service.FileContext{
-			Filename:         aws.String(fileName),
-			LeftFileContent:  &prompt,
-			RightFileContent: aws.String(""),
-			ProgrammingLanguage: &service.ProgrammingLanguage{
-				LanguageName: aws.String(languageName),
-			},
-		},
-		MaxResults: aws.Int64(5),
+		FileContext: fileContext(request),
+		MaxResults:  aws.Int64(5),
 	})
 	if err != nil {
 		log.Debug().Msgf("Error fetching suggestions with CodeWhisperer: %s", err)
-		return nil, err
+		return nil, engineError(err)
 	}
 	log.Debug().Msgf("Fetched %d suggestions with CodeWhisperer, next token is %s", len(result.Completions),
 		aws.StringValue(result.NextToken))
 	return &codeWhispererSuggestion{
-		prompt:     prompt,
+		prompt:     request.Prompt,
 		completion: result,
 	}, nil
 
 }
 
-// TopSuggestions returns the top suggestions for the given prompt and current suggestion.
-func (c *CodeWhisperer) TopSuggestions(prompt string, current engine.Suggestion) ([]engine.Suggestion, error) {
+// TopSuggestions returns the top suggestions for the given request and current suggestion.
+func (c *CodeWhisperer) TopSuggestions(request engine.Request, current engine.Suggestion) ([]engine.Suggestion, error) {
 	suggestion, ok := current.(*codeWhispererSuggestion)
 	if !ok {
 		return nil, nil
@@ -90,7 +132,7 @@ func (c *CodeWhisperer) TopSuggestions(prompt string, current engine.Suggestion)
 	suggestions := make([]engine.Suggestion, 0, len(suggestion.completion.Completions))
 	for i := 0; i < len(suggestion.completion.Completions); i++ {
 		suggestions = append(suggestions, &codeWhispererSuggestion{
-			prompt:          prompt,
+			prompt:          request.Prompt,
 			completion:      suggestion.completion,
 			completionIndex: i,
 		})
@@ -98,26 +140,19 @@ func (c *CodeWhisperer) TopSuggestions(prompt string, current engine.Suggestion)
 	if suggestion.completion.NextToken != nil {
 		// There may be more suggestions, fetch them
 		result, err := c.sessionManager.GenerateCompletions(&service.GenerateCompletionsInput{
-			FileContext: &service.FileContext{
-				Filename:         aws.String(fileName),
-				LeftFileContent:  &prompt,
-				RightFileContent: aws.String(""),
-				ProgrammingLanguage: &service.ProgrammingLanguage{
-					LanguageName: aws.String(languageName),
-				},
-			},
-			MaxResults: aws.Int64(5),
-			NextToken:  suggestion.completion.NextToken,
+			FileContext: fileContext(request),
+			MaxResults:  aws.Int64(5),
+			NextToken:   suggestion.completion.NextToken,
 		})
 		if err != nil {
 			log.Debug().Msgf("Error fetching suggestions with CodeWhisperer: %s", err)
-			return nil, err
+			return nil, engineError(err)
 		}
 		log.Debug().Msgf("Fetched %d additional suggestions with CodeWhisperer, next token is %s", len(result.Completions),
 			aws.StringValue(result.NextToken))
 		for i := 0; i < len(result.Completions); i++ {
 			suggestions = append(suggestions, &codeWhispererSuggestion{
-				prompt:          prompt,
+				prompt:          request.Prompt,
 				completion:      result,
 				completionIndex: i,
 			})
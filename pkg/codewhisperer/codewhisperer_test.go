@@ -0,0 +1,165 @@
+package codewhisperer
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/testing/fakes"
+)
+
+type testDisplay struct {
+	messages []string
+}
+
+func (d *testDisplay) ShowMessage(message string) {
+	d.messages = append(d.messages, message)
+}
+
+func newTestEngine(t *testing.T, fake *fakes.CodeWhisperer) (*CodeWhisperer, *config.Repository) {
+	repo := config.NewRepository(t.TempDir())
+	e, err := NewSuggestionEngineWithEndpoints(repo, &testDisplay{}, Endpoints{
+		CodeWhisperer: fake.URL,
+		SSOOIDC:       fake.URL,
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	return e, repo
+}
+
+func TestSuggestAndPagination(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+	fake.SetPendingPolls(2)
+	fake.SetCompletions(
+		fakes.Completion{Content: "print(df.head())\nprint(df.tail())"},
+		fakes.Completion{Content: "2", References: []fakes.Reference{{LicenseName: "MIT", Repository: "pandas"}}},
+		fakes.Completion{Content: "3"},
+		fakes.Completion{Content: "4"},
+		fakes.Completion{Content: "5"},
+		fakes.Completion{Content: "6"},
+		fakes.Completion{Content: "7"},
+	)
+
+	e, repo := newTestEngine(t, fake)
+	var token ssooidc.CreateTokenOutput
+	assert.NoError(t, repo.Load("codewhisperer-token", &token))
+	var stored Token
+	assert.NoError(t, repo.Load("codewhisperer-token", &stored))
+	if assert.NotNil(t, stored.ExpiresAt) {
+		assert.True(t, stored.ExpiresAt.After(time.Now().Add(59*time.Minute)))
+	}
+
+	request := engine.Request{
+		Prompt:   ">>> import pandas as pd\n>>> ",
+		Suffix:   ")",
+		Language: engine.Language{Name: "python", FileName: "script.py"},
+	}
+	suggestion, err := e.Suggest(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "print(df.head())", suggestion.Text())
+
+	requests := fake.Requests()
+	assert.Equal(t, 1, len(requests))
+	assert.Equal(t, "script.py", requests[0].FileContext.Filename)
+	assert.Equal(t, "python", requests[0].FileContext.ProgrammingLanguage.LanguageName)
+	assert.Equal(t, request.Prompt, requests[0].FileContext.LeftFileContent)
+	assert.Equal(t, ")", requests[0].FileContext.RightFileContent)
+
+	suggestions, err := e.TopSuggestions(request, suggestion)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(suggestions))
+	assert.Equal(t, "7", suggestions[6].Text())
+	references := suggestions[1].(engine.Inspectable).References()
+	assert.Equal(t, []engine.Reference{{LicenseName: "MIT", Repository: "pandas"}}, references)
+}
+
+func TestExpiredTokenIsRefreshed(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+
+	e, repo := newTestEngine(t, fake)
+	var before ssooidc.CreateTokenOutput
+	assert.NoError(t, repo.Load("codewhisperer-token", &before))
+
+	fake.ExpireTokens()
+	suggestion, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	assert.Equal(t, "ls -la", suggestion.Text())
+
+	var after ssooidc.CreateTokenOutput
+	assert.NoError(t, repo.Load("codewhisperer-token", &after))
+	assert.NotEqual(t, *before.AccessToken, *after.AccessToken)
+}
+
+func TestTokenRefreshedByAnotherInstance(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+	first, repo := newTestEngine(t, fake)
+	second, err := NewSuggestionEngineWithEndpoints(repo, &testDisplay{}, Endpoints{
+		CodeWhisperer: fake.URL,
+		SSOOIDC:       fake.URL,
+	})
+	assert.NoError(t, err)
+
+	// Refresh tokens can only be used once: the second instance uses the token the first one refreshed.
+	fake.ExpireTokens()
+	_, err = first.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	suggestion, err := second.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	assert.Equal(t, "ls -la", suggestion.Text())
+}
+
+func TestUnreadableClientIsNotReplaced(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "codewhisperer-client.json"), []byte("{"), 0o600))
+
+	_, err := NewSuggestionEngineWithEndpoints(config.NewRepository(dir), &testDisplay{}, Endpoints{
+		CodeWhisperer: fake.URL,
+		SSOOIDC:       fake.URL,
+	})
+	assert.Error(t, err)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "codewhisperer-client.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "{", string(data))
+}
+
+func TestScriptedFailures(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+	e, _ := newTestEngine(t, fake)
+
+	// Failed requests are retried according to the default transport policy
+	fake.FailNext(fakes.MalformedJSON, fakes.MalformedJSON, fakes.MalformedJSON)
+	_, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.True(t, errors.Is(err, engine.ErrUnavailable), "%v", err)
+
+	fake.FailNext(fakes.Throttle)
+	suggestion, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	assert.Equal(t, "ls -la", suggestion.Text())
+}
+
+func TestRefreshFailureIsUnauthenticated(t *testing.T) {
+	fake := fakes.NewCodeWhisperer()
+	defer fake.Close()
+	e, _ := newTestEngine(t, fake)
+
+	// Expire the access token and make the refresh token unknown to the service
+	fake.ExpireTokens()
+	e.sessionManager.currentToken.RefreshToken = aws.String("revoked")
+
+	_, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.True(t, errors.Is(err, engine.ErrUnauthenticated), "%v", err)
+}
@@ -0,0 +1,49 @@
+package codewhisperer
+
+import (
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ssooidc"
+	"github.com/jjviana/codex/pkg/codewhisperer/service"
+	"github.com/jjviana/codex/pkg/engine"
+)
+
+// errCodeServiceQuotaExceeded is not part of the service model but may be returned by the service.
+const errCodeServiceQuotaExceeded = "ServiceQuotaExceededException"
+
+// engineError maps errors returned by the CodeWhisperer and SSO OIDC clients to engine errors.
+func engineError(err error) error {
+	if err == nil {
+		return nil
+	}
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return engine.NewError(engine.ErrUnavailable, err)
+	}
+	switch awsErr.Code() {
+	case service.ErrCodeAccessDeniedException,
+		ssooidc.ErrCodeExpiredTokenException,
+		ssooidc.ErrCodeInvalidGrantException,
+		ssooidc.ErrCodeInvalidClientException,
+		ssooidc.ErrCodeUnauthorizedClientException:
+		return engine.NewError(engine.ErrUnauthenticated, err)
+	case service.ErrCodeThrottlingException, ssooidc.ErrCodeSlowDownException:
+		return engine.NewRateLimitedError(0, err)
+	case errCodeServiceQuotaExceeded:
+		return engine.NewError(engine.ErrQuotaExceeded, err)
+	case service.ErrCodeValidationException,
+		service.ErrCodeConflictException,
+		service.ErrCodeResourceNotFoundException,
+		ssooidc.ErrCodeInvalidRequestException:
+		return engine.NewError(engine.ErrInvalidRequest, err)
+	case service.ErrCodeInternalServerException,
+		request.ErrCodeRequestError,
+		request.ErrCodeSerialization,
+		request.ErrCodeResponseTimeout:
+		return engine.NewError(engine.ErrUnavailable, err)
+	}
+	if failure, ok := err.(awserr.RequestFailure); ok && failure.StatusCode() < 500 {
+		return engine.NewError(engine.ErrInvalidRequest, err)
+	}
+	return engine.NewError(engine.ErrUnavailable, err)
+}
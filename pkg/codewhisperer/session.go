@@ -1,21 +1,27 @@
 package codewhisperer
 
 import (
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssooidc"
 	"github.com/jjviana/codex/pkg/codewhisperer/service"
+	"github.com/jjviana/codex/pkg/transport"
 	"github.com/rs/zerolog/log"
 	"net/http"
+	"os"
 	"time"
 )
 
 type configRepository interface {
 	Store(name string, config interface{}) error
 	Load(name string, config interface{}) error
+	Update(name string, config interface{}, modify func() error) error
 }
 
 type display interface {
@@ -30,9 +36,37 @@ const refreshGrantType = "refresh_token"
 
 var startUrl = aws.String("https://view.awsapps.com/start")
 
+// Endpoints holds the service endpoints a session talks to.
+type Endpoints struct {
+	// CodeWhisperer is the CodeWhisperer service endpoint.
+	CodeWhisperer string
+	// SSOOIDC is the SSO OIDC endpoint used to authenticate. The AWS default for the region is used when empty.
+	SSOOIDC string
+}
+
+// DefaultEndpoints are the public AWS endpoints.
+var DefaultEndpoints = Endpoints{CodeWhisperer: endpoint}
+
 var scopes = []*string{aws.String("codewhisperer:completions"),
 	aws.String("codewhisperer:analysis")}
 
+// Token is the token stored under the name codewhisperer-token.
+type Token struct {
+	ssooidc.CreateTokenOutput
+	// ExpiresAt is when the access token expires. Tokens stored by earlier versions do not have it.
+	ExpiresAt *time.Time
+}
+
+// newToken returns the token to store for the output of CreateToken, received now.
+func newToken(output *ssooidc.CreateTokenOutput) *Token {
+	token := &Token{CreateTokenOutput: *output}
+	if output.ExpiresIn != nil {
+		expiresAt := time.Now().Add(time.Duration(*output.ExpiresIn) * time.Second)
+		token.ExpiresAt = &expiresAt
+	}
+	return token
+}
+
 type SessionManager struct {
 	configRepository configRepository
 	display          display
@@ -44,15 +78,33 @@ type SessionManager struct {
 	client           *ssooidc.RegisterClientOutput
 }
 
-func NewSessionManager(configRepository configRepository, display display) *SessionManager {
-	bearer := BearerHTTPRoundTRipper{RoundTripper: http.DefaultTransport}
+func NewSessionManager(configRepository configRepository, display display, endpoints Endpoints,
+	policy transport.Policy) *SessionManager {
+	// Retries are left to the SDK retryer, which knows which service errors are worth retrying.
+	retryer := client.DefaultRetryer{
+		NumMaxRetries:    policy.MaxRetries,
+		MinRetryDelay:    time.Duration(policy.InitialBackoff),
+		MinThrottleDelay: time.Duration(policy.InitialBackoff),
+		MaxRetryDelay:    time.Duration(policy.MaxBackoff),
+		MaxThrottleDelay: time.Duration(policy.MaxBackoff),
+	}
+	transportPolicy := policy
+	transportPolicy.MaxRetries = 0
+	roundTripper := transport.NewTransport(transportPolicy)
+
+	bearer := BearerHTTPRoundTRipper{RoundTripper: roundTripper}
 	httpClient := &http.Client{Transport: &bearer}
 	awsSession := session.Must(session.NewSession())
-	service := service.New(awsSession, aws.NewConfig().WithRegion(awsRegion).WithCredentials(
-		credentials.AnonymousCredentials).WithEndpoint(endpoint).
-		WithHTTPClient(httpClient))
-	ssoidc := ssooidc.New(awsSession, aws.NewConfig().WithRegion(awsRegion).WithCredentials(
-		credentials.AnonymousCredentials))
+	serviceConfig := aws.NewConfig().WithRegion(awsRegion).WithCredentials(
+		credentials.AnonymousCredentials).WithEndpoint(endpoints.CodeWhisperer).
+		WithHTTPClient(httpClient)
+	service := service.New(awsSession, request.WithRetryer(serviceConfig, retryer))
+	ssoidcConfig := aws.NewConfig().WithRegion(awsRegion).WithCredentials(credentials.AnonymousCredentials).
+		WithHTTPClient(&http.Client{Transport: roundTripper})
+	if endpoints.SSOOIDC != "" {
+		ssoidcConfig = ssoidcConfig.WithEndpoint(endpoints.SSOOIDC)
+	}
+	ssoidc := ssooidc.New(awsSession, request.WithRetryer(ssoidcConfig, retryer))
 
 	return &SessionManager{
 		configRepository: configRepository,
@@ -81,15 +133,35 @@ func (s *SessionManager) Start() error {
 	return nil
 }
 
+// Login authorizes the client again through the device flow and replaces the current token.
+func (s *SessionManager) Login() error {
+	token, err := s.authorizeClient()
+	if err != nil {
+		return err
+	}
+	err = s.configRepository.Store("codewhisperer-token", newToken(token))
+	if err != nil {
+		return err
+	}
+	s.currentToken = token
+	s.bearer.Token = *token.AccessToken
+	return nil
+}
+
 func (s *SessionManager) loadOrCreateToken() (*ssooidc.CreateTokenOutput, error) {
-	token := &ssooidc.CreateTokenOutput{}
-	err := s.configRepository.Load("codewhisperer-token", token)
+	stored := &Token{}
+	err := s.configRepository.Load("codewhisperer-token", stored)
+	token := &stored.CreateTokenOutput
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// A token that cannot be decrypted must not be silently replaced.
+		return nil, fmt.Errorf("failed to load the CodeWhisperer token: %w", err)
+	}
 	if err != nil {
 		token, err = s.authorizeClient()
 		if err != nil {
 			return nil, err
 		}
-		err = s.configRepository.Store("codewhisperer-token", token)
+		err = s.configRepository.Store("codewhisperer-token", newToken(token))
 		if err != nil {
 			return nil, err
 		}
@@ -148,8 +220,8 @@ func (s *SessionManager) GenerateCompletions(request *service.GenerateCompletion
 	response, err := s.service.GenerateCompletions(request)
 	if err != nil {
 		log.Debug().Msgf("Error calling GenerateCompletions: %v", err)
-		if err.(awserr.Error).Code() == ssooidc.ErrCodeExpiredTokenException ||
-			err.(awserr.Error).Code() == ssooidc.ErrCodeAccessDeniedException {
+		if awsErr, ok := err.(awserr.Error); ok && (awsErr.Code() == ssooidc.ErrCodeExpiredTokenException ||
+			awsErr.Code() == ssooidc.ErrCodeAccessDeniedException) {
 			log.Debug().Msgf("Refreshing token")
 			// Refresh token
 			token, err := s.refreshToken()
@@ -161,30 +233,48 @@ func (s *SessionManager) GenerateCompletions(request *service.GenerateCompletion
 			s.bearer.Token = *token.AccessToken
 			return s.service.GenerateCompletions(request)
 		}
+		return nil, err
 	}
 	return response, nil
 }
 
+// refreshToken refreshes the token and stores it. Other instances of witty may refresh it at the same time: the
+// stored token is updated under its lock, and a token they refreshed meanwhile is used instead of refreshing
+// it again.
 func (s *SessionManager) refreshToken() (*ssooidc.CreateTokenOutput, error) {
-	token, err := s.ssooidc.CreateToken(&ssooidc.CreateTokenInput{
-		ClientId:     s.client.ClientId,
-		ClientSecret: s.client.ClientSecret,
-		GrantType:    aws.String(refreshGrantType),
-		RefreshToken: s.currentToken.RefreshToken,
+	token := &Token{}
+	err := s.configRepository.Update("codewhisperer-token", token, func() error {
+		if token.AccessToken != nil && aws.StringValue(token.AccessToken) != aws.StringValue(s.currentToken.AccessToken) {
+			return nil
+		}
+		refreshed, err := s.ssooidc.CreateToken(&ssooidc.CreateTokenInput{
+			ClientId:     s.client.ClientId,
+			ClientSecret: s.client.ClientSecret,
+			GrantType:    aws.String(refreshGrantType),
+			RefreshToken: s.currentToken.RefreshToken,
+		})
+		if err != nil {
+			return err
+		}
+		if refreshed.RefreshToken == nil {
+			refreshed.RefreshToken = s.currentToken.RefreshToken
+		}
+		*token = *newToken(refreshed)
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	err = s.configRepository.Store("codewhisperer-token", token)
-	if err != nil {
-		return nil, err
-	}
-	return token, nil
+	return &token.CreateTokenOutput, nil
 }
 
 func (s *SessionManager) loadOrRegisterClient() (*ssooidc.RegisterClientOutput, error) {
 	client := &ssooidc.RegisterClientOutput{}
 	err := s.configRepository.Load("codewhisperer-client", client)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// A client registration that cannot be decrypted must not be silently replaced.
+		return nil, fmt.Errorf("failed to load the CodeWhisperer client registration: %w", err)
+	}
 	if err != nil {
 		client, err = s.registerClient()
 		if err != nil {
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/transport"
 	"github.com/rs/zerolog/log"
 	"io/ioutil"
 	"math"
@@ -16,9 +18,12 @@ import (
 )
 
 type CompletionParameters struct {
-	Prompt           string
-	EngineID         string
-	APIKey           string
+	Prompt   string
+	Suffix   string `json:",omitempty"`
+	EngineID string
+	// APIKey is kept in memory only: it is stored as the openai-api-key secret. Parameters stored by earlier
+	// versions may still have it.
+	APIKey           string `json:",omitempty"`
 	Temperature      float64
 	MaxTokens        int
 	TopP             float64
@@ -26,20 +31,22 @@ type CompletionParameters struct {
 	PresencePenalty  float64
 	Stop             []string
 	LogProbs         int
+	// BaseURL overrides the OpenAI engines API endpoint. Used to point witty at compatible servers.
+	BaseURL string `json:",omitempty"`
 }
 
-// GenerateCompletions generates a list of possible completions for the given prompt.
-func GenerateCompletions(params CompletionParameters) (Completion, error) {
+// GenerateCompletions generates a list of possible completions for the given prompt, using the given HTTP client.
+func GenerateCompletions(client *http.Client, params CompletionParameters) (Completion, error) {
 	var completion Completion
 	var err error
 	if params.Prompt == "" {
-		return completion, fmt.Errorf("prompt is required")
+		return completion, engine.NewError(engine.ErrInvalidRequest, fmt.Errorf("prompt is required"))
 	}
 	if params.EngineID == "" {
-		return completion, fmt.Errorf("engine_id is required")
+		return completion, engine.NewError(engine.ErrInvalidRequest, fmt.Errorf("engine_id is required"))
 	}
 	if params.APIKey == "" {
-		return completion, fmt.Errorf("api_key is required")
+		return completion, engine.NewError(engine.ErrUnauthenticated, fmt.Errorf("api_key is required"))
 	}
 	if params.MaxTokens == 0 {
 		params.MaxTokens = 64
@@ -48,7 +55,11 @@ func GenerateCompletions(params CompletionParameters) (Completion, error) {
 		params.TopP = 1
 	}
 
-	url := fmt.Sprintf("%s/%s/completions", baseURL, params.EngineID)
+	if params.BaseURL == "" {
+		params.BaseURL = baseURL
+	}
+
+	url := fmt.Sprintf("%s/%s/completions", params.BaseURL, params.EngineID)
 
 	// Convert params.Stop into a valid json string
 	stopJSON, err := json.Marshal(params.Stop)
@@ -62,8 +73,19 @@ func GenerateCompletions(params CompletionParameters) (Completion, error) {
 		return completion, err
 	}
 
+	// The suffix is only sent when present, as not every model supports inserting text.
+	suffix := ""
+	if params.Suffix != "" {
+		suffixJSON, err := json.Marshal(params.Suffix)
+		if err != nil {
+			return completion, err
+		}
+		suffix = fmt.Sprintf(`
+  "suffix": %s,`, suffixJSON)
+	}
+
 	body := fmt.Sprintf(`{
-  "prompt": %s,
+  "prompt": %s,%s
   "temperature": %f,
   "max_tokens": %d,
   "top_p": %f,
@@ -71,21 +93,21 @@ func GenerateCompletions(params CompletionParameters) (Completion, error) {
   "presence_penalty": %f,
   "logprobs": %d,
   "stop": %s
-}`, promptJSON, params.Temperature, params.MaxTokens, params.TopP, params.FrequencyPenalty, params.PresencePenalty, params.LogProbs, string(stopJSON))
+}`, promptJSON, suffix, params.Temperature, params.MaxTokens, params.TopP, params.FrequencyPenalty, params.PresencePenalty, params.LogProbs, string(stopJSON))
 
-	resp, err := httpPost(url, params.APIKey, body)
+	resp, httpResp, err := httpPost(client, url, params.APIKey, body)
 	if err != nil {
-		return completion, err
+		return completion, engine.NewError(engine.ErrUnavailable, err)
 	}
 
 	log.Debug().Msgf("response: %s", resp)
 
 	err = json.Unmarshal(resp, &completion)
-	if err != nil {
-		return completion, err
+	if httpResp.StatusCode != http.StatusOK || len(completion.Error) > 0 {
+		return completion, requestError(httpResp, completion.Error)
 	}
-	if len(completion.Error) > 0 {
-		return completion, fmt.Errorf("request error: %+v", completion.Error)
+	if err != nil {
+		return completion, engine.NewError(engine.ErrUnavailable, fmt.Errorf("invalid response: %w", err))
 	}
 
 	return completion, nil
@@ -113,6 +135,16 @@ func (c *Choice) Text() string {
 	return c.ChoiceText
 }
 
+// References returns nil, as OpenAI does not track code references.
+func (c *Choice) References() []engine.Reference {
+	return nil
+}
+
+// RawResponse returns the choice as decoded from the completions response.
+func (c *Choice) RawResponse() interface{} {
+	return c
+}
+
 type Logprobs struct {
 	TextOffset    []float64            `json:"text_offset"`
 	TokenLogProbs []float64            `json:"token_logprobs"`
@@ -129,42 +161,44 @@ func (l Logprobs) TokenProbabilities() []float64 {
 	return probs
 }
 
-func httpPost(url, apiKey, body string) ([]byte, error) {
+func httpPost(client *http.Client, url, apiKey, body string) ([]byte, *http.Response, error) {
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return respBody, nil
+	return respBody, resp, nil
 }
 
 type SuggestionEngine struct {
+	configRepository     configRepository
 	completionParameters CompletionParameters
+	httpClient           *http.Client
 }
 
 const (
 	gpt_3_5_turbo = "gpt-3.5-turbo-instruct"
 )
 
-func (s *SuggestionEngine) suggestWithEngine(engine, prompt string) (*Choice, error) {
+func (s *SuggestionEngine) suggestWithEngine(engine, prompt, suffix string) (*Choice, error) {
 	log.Debug().Msgf("requesting suggestion to %s  with  prompt: %s", engine, prompt)
 
 	request := s.completionParameters
 	request.Prompt = prompt
+	request.Suffix = suffix
 	request.EngineID = engine
 
-	completion, err := GenerateCompletions(request)
+	completion, err := GenerateCompletions(s.httpClient, request)
 	if err != nil {
 		return nil, err
 	}
@@ -180,10 +214,13 @@ func (s *SuggestionEngine) suggestWithEngine(engine, prompt string) (*Choice, er
 	}
 	return nil, nil
 }
-func (s *SuggestionEngine) Suggest(prompt string) (engine.Suggestion, error) {
-	
-	suggestion, err := s.suggestWithEngine(gpt_3_5_turbo, prompt)
+func (s *SuggestionEngine) Suggest(request engine.Request) (engine.Suggestion, error) {
 
+	suggestion, err := s.suggestWithEngine(gpt_3_5_turbo, request.Prompt, request.Suffix)
+	if suggestion == nil {
+		// A nil *Choice would make a non-nil engine.Suggestion.
+		return nil, err
+	}
 	return suggestion, err
 }
 
@@ -208,17 +245,23 @@ func topChoices(m map[string]float64) []string {
 	return returnChoices
 }
 
-func (s *SuggestionEngine) TopSuggestions(prompt string, current engine.Suggestion) ([]engine.Suggestion, error) {
-	choice := current.(*Choice)
-	topProbs := choice.Logprobs.TopLogProbs[0]
-	topChoices := topChoices(topProbs)
+func (s *SuggestionEngine) TopSuggestions(request engine.Request, current engine.Suggestion) ([]engine.Suggestion, error) {
+	choice, ok := current.(*Choice)
+	if !ok || choice == nil || len(choice.Logprobs.TopLogProbs) == 0 {
+		return nil, nil
+	}
+	topChoices := topChoices(choice.Logprobs.TopLogProbs[0])
 	var suggestions []engine.Suggestion
 	for _, c := range topChoices {
-		suggestion, err := s.Suggest(prompt + c)
+		next := request
+		next.Prompt = request.Prompt + c
+		suggestion, err := s.Suggest(next)
 		if err != nil {
 			return nil, err
 		}
-		suggestions = append(suggestions, suggestion)
+		if suggestion != nil {
+			suggestions = append(suggestions, suggestion)
+		}
 	}
 	return suggestions, nil
 }
@@ -230,29 +273,88 @@ type configRepository interface {
 
 func NewSuggestionEngine(configRepository configRepository) (*SuggestionEngine, error) {
 
+	policy, err := transport.LoadPolicy(configRepository)
+	if err != nil {
+		return nil, err
+	}
+
 	completionParameters := CompletionParameters{}
-	err := configRepository.Load("OPENAI_COMPLETION_PARAMETERS", &completionParameters)
+	err = configRepository.Load("OPENAI_COMPLETION_PARAMETERS", &completionParameters)
 
 	if err != nil {
 		completionParameters.MaxTokens = 64
 		completionParameters.Temperature = 0.0
 		completionParameters.Stop = []string{"\n"}
 		completionParameters.LogProbs = 10
-		// Read the API key from stdin
-		fmt.Print("Enter OpenAI API key: ")
-		reader := bufio.NewReader(os.Stdin)
-		apiKey, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-		completionParameters.APIKey = strings.TrimSpace(apiKey)
 
 		err = configRepository.Store("OPENAI_COMPLETION_PARAMETERS", completionParameters)
 		if err != nil {
 			return nil, err
 		}
 	}
+
+	completionParameters.APIKey, err = loadAPIKey(configRepository, completionParameters)
+	if err != nil {
+		return nil, err
+	}
 	return &SuggestionEngine{
+		configRepository:     configRepository,
 		completionParameters: completionParameters,
+		httpClient:           transport.NewClient(policy),
 	}, nil
 }
+
+// Login asks for a new API key and stores it.
+func (s *SuggestionEngine) Login() error {
+	apiKey, err := readAPIKey()
+	if err != nil {
+		return err
+	}
+	err = s.configRepository.Store(apiKeySecret, apiKey)
+	if err != nil {
+		return err
+	}
+	s.completionParameters.APIKey = apiKey
+	return nil
+}
+
+// apiKeySecret is the name the API key is stored under.
+const apiKeySecret = "openai-api-key"
+
+// loadAPIKey returns the stored API key, asking for it if there is none. An API key found in the completion
+// parameters, where earlier versions stored it, is moved out of them.
+func loadAPIKey(configRepository configRepository, completionParameters CompletionParameters) (string, error) {
+	if completionParameters.APIKey != "" {
+		apiKey := completionParameters.APIKey
+		if err := configRepository.Store(apiKeySecret, apiKey); err != nil {
+			return "", err
+		}
+		completionParameters.APIKey = ""
+		return apiKey, configRepository.Store("OPENAI_COMPLETION_PARAMETERS", completionParameters)
+	}
+
+	var apiKey string
+	err := configRepository.Load(apiKeySecret, &apiKey)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to load the OpenAI API key: %w", err)
+	}
+	if apiKey != "" {
+		return apiKey, nil
+	}
+	apiKey, err = readAPIKey()
+	if err != nil {
+		return "", err
+	}
+	return apiKey, configRepository.Store(apiKeySecret, apiKey)
+}
+
+// readAPIKey reads the API key from stdin
+func readAPIKey() (string, error) {
+	fmt.Print("Enter OpenAI API key: ")
+	reader := bufio.NewReader(os.Stdin)
+	apiKey, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(apiKey), nil
+}
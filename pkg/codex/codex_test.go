@@ -0,0 +1,122 @@
+package codex
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/transport"
+	"github.com/jjviana/codex/testing/fakes"
+)
+
+func newTestEngine(t *testing.T, fake *fakes.OpenAI, policy transport.Policy) *SuggestionEngine {
+	repo := config.NewRepository(t.TempDir())
+	assert.NoError(t, repo.Store("transport", policy))
+	err := repo.Store("OPENAI_COMPLETION_PARAMETERS", CompletionParameters{
+		APIKey:    fakes.OpenAIKey,
+		MaxTokens: 64,
+		Stop:      []string{"\n"},
+		LogProbs:  10,
+		BaseURL:   fake.EnginesURL(),
+	})
+	assert.NoError(t, err)
+	e, err := NewSuggestionEngine(repo)
+	assert.NoError(t, err)
+	return e
+}
+
+func TestSuggest(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	fake.SetCompletions("git status", "git diff")
+	e := newTestEngine(t, fake, transport.DefaultPolicy())
+
+	suggestion, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	assert.Equal(t, "git status", suggestion.Text())
+
+	requests := fake.Requests()
+	assert.Equal(t, 1, len(requests))
+	assert.Equal(t, gpt_3_5_turbo, requests[0].Model)
+	assert.Equal(t, "$ ", requests[0].Prompt)
+	assert.Equal(t, []string{"\n"}, requests[0].Stop)
+
+	suggestions, err := e.TopSuggestions(engine.Request{Prompt: "$ "}, suggestion)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(suggestions))
+}
+
+func TestSuggestWithoutChoices(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	fake.SetCompletions()
+	e := newTestEngine(t, fake, transport.DefaultPolicy())
+
+	suggestion, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	assert.True(t, suggestion == nil, "no choice is a nil suggestion")
+
+	suggestions, err := e.TopSuggestions(engine.Request{Prompt: "$ "}, &Choice{ChoiceText: "ls"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(suggestions))
+	suggestions, err = e.TopSuggestions(engine.Request{Prompt: "$ "}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(suggestions))
+}
+
+func TestSuggestWithSuffix(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	e := newTestEngine(t, fake, transport.DefaultPolicy())
+
+	_, err := e.Suggest(engine.Request{Prompt: "$ git ", Suffix: " --amend"})
+	assert.NoError(t, err)
+	assert.Equal(t, " --amend", fake.Requests()[0].Suffix)
+}
+
+func TestScriptedFailures(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	policy := transport.DefaultPolicy()
+	policy.MaxRetries = 0
+	e := newTestEngine(t, fake, policy)
+
+	expected := map[fakes.Failure]error{
+		fakes.Throttle:      engine.ErrRateLimited,
+		fakes.ExpiredToken:  engine.ErrUnauthenticated,
+		fakes.MalformedJSON: engine.ErrUnavailable,
+		fakes.ServerError:   engine.ErrUnavailable,
+	}
+	for failure, kind := range expected {
+		fake.FailNext(failure)
+		_, err := e.Suggest(engine.Request{Prompt: "$ "})
+		assert.True(t, errors.Is(err, kind), "%s: %v", failure, err)
+	}
+
+	fake.FailNext(fakes.Throttle)
+	_, err := e.Suggest(engine.Request{Prompt: "$ "})
+	retryAfter, ok := engine.RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, retryAfter)
+
+	fake.SetAPIKey("rotated")
+	_, err = e.Suggest(engine.Request{Prompt: "$ "})
+	assert.True(t, errors.Is(err, engine.ErrUnauthenticated))
+}
+
+func TestTransientFailuresAreRetried(t *testing.T) {
+	fake := fakes.NewOpenAI()
+	defer fake.Close()
+	policy := transport.DefaultPolicy()
+	policy.InitialBackoff = transport.Duration(time.Millisecond)
+	e := newTestEngine(t, fake, policy)
+
+	fake.FailNext(fakes.ServerError, fakes.ServerError)
+	suggestion, err := e.Suggest(engine.Request{Prompt: "$ "})
+	assert.NoError(t, err)
+	assert.Equal(t, "ls -la", suggestion.Text())
+	assert.Equal(t, 3, len(fake.Requests()))
+}
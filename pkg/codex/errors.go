@@ -0,0 +1,36 @@
+package codex
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/transport"
+)
+
+// requestError maps a failed OpenAI response to an engine error, based on the HTTP status and
+// the error object in the response body.
+func requestError(resp *http.Response, apiError map[string]interface{}) error {
+	var err error
+	if len(apiError) > 0 {
+		err = fmt.Errorf("request error: %+v", apiError)
+	} else {
+		err = fmt.Errorf("request failed with status %s", resp.Status)
+	}
+	code, _ := apiError["code"].(string)
+	errorType, _ := apiError["type"].(string)
+
+	switch {
+	case code == "insufficient_quota" || errorType == "insufficient_quota":
+		return engine.NewError(engine.ErrQuotaExceeded, err)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden ||
+		code == "invalid_api_key":
+		return engine.NewError(engine.ErrUnauthenticated, err)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return engine.NewRateLimitedError(transport.ParseRetryAfter(resp.Header.Get("Retry-After")), err)
+	case resp.StatusCode >= 500:
+		return engine.NewError(engine.ErrUnavailable, err)
+	default:
+		return engine.NewError(engine.ErrInvalidRequest, err)
+	}
+}
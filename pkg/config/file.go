@@ -0,0 +1,352 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding the configuration file.
+const EnvPrefix = "WITTY_"
+
+// Environment variables with the prefix that are not settings. They match no key of the configuration.
+const (
+	// ProfileEnv selects a profile of the configuration file.
+	ProfileEnv = EnvPrefix + "PROFILE"
+	// PassphraseEnv holds the passphrase the secrets encryption key is derived from.
+	PassphraseEnv = EnvPrefix + "PASSPHRASE"
+)
+
+// File is the configuration file, config.toml in the configuration directory. Its top-level keys are the
+// settings of witty itself. Its tables are sections named after the configurations of the repository, such
+// as [scrollback] for scrollback.json, and [profile.<name>] tables override any of them when the profile is
+// selected.
+//
+// Keys are checked against the types they are decoded into: keys match field names ignoring case and
+// underscores, so both max_lines and MaxLines set MaxLines, and unknown keys are reported as errors.
+type File struct {
+	path     string
+	settings map[string]interface{}
+	sections map[string]map[string]interface{}
+	// env holds the values of the environment variables by section, "" for the settings.
+	env map[string]map[string]interface{}
+}
+
+// LoadFile loads the configuration file at path, applying the given profile if not empty. A missing file is
+// an empty configuration. sections lists the tables the file may contain, beside the profiles.
+func LoadFile(path, profile string, sections []string) (*File, error) {
+	f := &File{path: path, settings: map[string]interface{}{}, sections: map[string]map[string]interface{}{},
+		env: map[string]map[string]interface{}{}}
+	for _, name := range sections {
+		f.sections[name] = map[string]interface{}{}
+	}
+
+	content := map[string]interface{}{}
+	if _, err := toml.DecodeFile(path, &content); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	profiles, _ := content["profile"].(map[string]interface{})
+	delete(content, "profile")
+	if profile != "" {
+		overrides, ok := profiles[profile].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown profile %q (defined: %s)", path, profile, strings.Join(keys(profiles), ", "))
+		}
+		merge(content, overrides)
+	}
+
+	for key, value := range content {
+		if _, ok := f.sections[key]; !ok {
+			f.settings[key] = value
+			continue
+		}
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s must be a table", path, key)
+		}
+		f.sections[key] = section
+	}
+	return f, nil
+}
+
+// ApplyEnv overrides the file with WITTY_* environment variables, given as in os.Environ. WITTY_SHELL sets
+// the shell setting, and WITTY_SCROLLBACK_MAX_LINES the max_lines key of the scrollback section. Variables
+// only apply when they match a known key: the environment is shared with other programs, so unknown keys are
+// only reported from the file.
+func (f *File) ApplyEnv(environ []string) {
+	for _, variable := range environ {
+		i := strings.IndexByte(variable, '=')
+		if i < 0 || !strings.HasPrefix(variable, EnvPrefix) {
+			continue
+		}
+		key, value := strings.ToLower(variable[len(EnvPrefix):i]), variable[i+1:]
+		section := ""
+		for name := range f.sections {
+			if strings.HasPrefix(key, strings.ToLower(name)+"_") {
+				section, key = name, key[len(name)+1:]
+				break
+			}
+		}
+		if f.env[section] == nil {
+			f.env[section] = map[string]interface{}{}
+		}
+		f.env[section][key] = value
+	}
+}
+
+// Settings decodes the top-level settings into v, which must point to a struct.
+func (f *File) Settings(v interface{}) error {
+	return f.decode("", f.withEnv("", f.settings, v), v)
+}
+
+// Section decodes the section with the given name into v, over its current content. It reports whether
+// the file has the section.
+func (f *File) Section(name string, v interface{}) (bool, error) {
+	section := f.withEnv(name, f.sections[name], v)
+	if len(section) == 0 {
+		return false, nil
+	}
+	return true, f.decode(name, section, v)
+}
+
+// withEnv returns the content of a section, "" for the settings, overridden with the environment variables
+// matching the keys of v.
+func (f *File) withEnv(section string, content map[string]interface{}, v interface{}) map[string]interface{} {
+	if len(f.env[section]) == 0 {
+		return content
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	merged := map[string]interface{}{}
+	for key, value := range content {
+		merged[key] = value
+	}
+	for key, value := range f.env[section] {
+		if t.Kind() == reflect.Struct {
+			if _, _, ok := findField(t, key); !ok {
+				continue
+			}
+		} else if t.Kind() != reflect.Map {
+			continue
+		}
+		// The file may spell the key differently.
+		for other := range merged {
+			if canonicalKey(other) == canonicalKey(key) {
+				delete(merged, other)
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+func (f *File) decode(path string, content map[string]interface{}, v interface{}) error {
+	normalized, err := normalize(content, reflect.TypeOf(v), path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	// Decoding through JSON gives the configuration types the same meaning in both formats.
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %s: %w", f.path, path, err)
+	}
+	return nil
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// normalize checks a decoded value against the type it is decoded into. It renames keys to the field names
+// they match and converts strings, from environment variables, to the numbers and booleans expected.
+func normalize(value interface{}, t reflect.Type, path string) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshaler) || reflect.PtrTo(t).Implements(textUnmarshaler) {
+		return value, nil
+	}
+
+	s, isString := value.(string)
+	switch t.Kind() {
+	case reflect.Struct:
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must be a table", path)
+		}
+		normalized := map[string]interface{}{}
+		for key, v := range table {
+			field, name, ok := findField(t, key)
+			if !ok {
+				return nil, fmt.Errorf("unknown key %q", join(path, key))
+			}
+			n, err := normalize(v, field.Type, join(path, key))
+			if err != nil {
+				return nil, err
+			}
+			normalized[name] = n
+		}
+		return normalized, nil
+	case reflect.Map:
+		table, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must be a table", path)
+		}
+		normalized := map[string]interface{}{}
+		for key, v := range table {
+			n, err := normalize(v, t.Elem(), join(path, key))
+			if err != nil {
+				return nil, err
+			}
+			normalized[key] = n
+		}
+		return normalized, nil
+	case reflect.Slice:
+		var items []interface{}
+		switch v := value.(type) {
+		case []interface{}:
+			items = v
+		case []map[string]interface{}:
+			for _, item := range v {
+				items = append(items, item)
+			}
+		case string:
+			for _, item := range strings.Split(v, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		default:
+			return nil, fmt.Errorf("%s must be a list", path)
+		}
+		normalized := make([]interface{}, len(items))
+		for i, item := range items {
+			n, err := normalize(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = n
+		}
+		return normalized, nil
+	case reflect.Bool:
+		if isString {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false", path)
+			}
+			return b, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if isString {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an integer", path)
+			}
+			return n, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if isString {
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be a number", path)
+			}
+			return n, nil
+		}
+	}
+	return value, nil
+}
+
+// findField returns the field of the struct type matching key, and the name it has in JSON.
+func findField(t reflect.Type, key string) (reflect.StructField, string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if canonicalKey(name) == canonicalKey(key) {
+			return field, name, true
+		}
+	}
+	return reflect.StructField{}, "", false
+}
+
+func canonicalKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// merge overrides the values of dst with the ones of src, merging tables.
+func merge(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcTable, srcIsTable := value.(map[string]interface{})
+		dstTable, dstIsTable := dst[key].(map[string]interface{})
+		if srcIsTable && dstIsTable {
+			merge(dstTable, srcTable)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+func keys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Layered loads the configurations stored in the repository and overrides them with the sections of the
+// configuration file. Configurations are stored in the repository only.
+type Layered struct {
+	*Repository
+	file *File
+}
+
+// NewLayered creates a layered configuration of the repository and the configuration file.
+func NewLayered(repository *Repository, file *File) *Layered {
+	return &Layered{Repository: repository, file: file}
+}
+
+// Load loads the configuration with the given name from the repository, then from the file section of the
+// same name. It returns an os.IsNotExist error when neither has it.
+func (l *Layered) Load(name string, config interface{}) error {
+	err := l.Repository.Load(name, config)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	found, fileErr := l.file.Section(name, config)
+	if fileErr != nil {
+		return fileErr
+	}
+	if found {
+		return nil
+	}
+	return err
+}
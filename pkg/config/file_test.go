@@ -0,0 +1,109 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+type testSettings struct {
+	Engine    string   `json:"engine"`
+	Shell     string   `json:"shell"`
+	ShellArgs []string `json:"shell_args"`
+}
+
+type testSection struct {
+	MaxLines int
+	Enabled  bool
+	Names    map[string]TestConfig
+}
+
+const testFile = `
+engine = "codewhisperer"
+shell = "/bin/bash"
+
+[scrollback]
+max_lines = 100
+Enabled = true
+
+[profile.work]
+engine = "gpt3.5"
+
+[profile.work.scrollback]
+max_lines = 200
+`
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestFile(t *testing.T) {
+	path := writeFile(t, testFile)
+
+	f, err := LoadFile(path, "", []string{"scrollback"})
+	assert.Nil(t, err)
+	var s testSettings
+	assert.Nil(t, f.Settings(&s))
+	assert.Equal(t, testSettings{Engine: "codewhisperer", Shell: "/bin/bash"}, s)
+	var section testSection
+	found, err := f.Section("scrollback", &section)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, testSection{MaxLines: 100, Enabled: true}, section)
+
+	f, err = LoadFile(path, "work", []string{"scrollback"})
+	assert.Nil(t, err)
+	f.ApplyEnv([]string{"WITTY_SHELL=/bin/zsh", "WITTY_SHELL_ARGS=--login,-i", "WITTY_SCROLLBACK_ENABLED=false",
+		"WITTY_PROFILE=other", "WITTY_PASSPHRASE=secret", "WITTY_SCROLLBACK_COLOR=red", "HOME=/root"})
+	s = testSettings{}
+	assert.Nil(t, f.Settings(&s))
+	assert.Equal(t, testSettings{Engine: "gpt3.5", Shell: "/bin/zsh", ShellArgs: []string{"--login", "-i"}}, s)
+	section = testSection{}
+	_, err = f.Section("scrollback", &section)
+	assert.Nil(t, err)
+	assert.Equal(t, testSection{MaxLines: 200}, section)
+
+	_, err = LoadFile(path, "home", []string{"scrollback"})
+	assert.NotNil(t, err)
+}
+
+func TestFileUnknownKeys(t *testing.T) {
+	f, err := LoadFile(writeFile(t, "engin = \"gpt3.5\"\n"), "", nil)
+	assert.Nil(t, err)
+	err = f.Settings(&testSettings{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `unknown key "engin"`)
+
+	f, err = LoadFile(writeFile(t, "[scrollback.names.a]\nhello = \"x\"\nbye = 1\n"), "", []string{"scrollback"})
+	assert.Nil(t, err)
+	_, err = f.Section("scrollback", &testSection{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `unknown key "scrollback.names.a.bye"`)
+
+	f, err = LoadFile(writeFile(t, "[scrollback]\nmax_lines = \"many\"\n"), "", []string{"scrollback"})
+	assert.Nil(t, err)
+	f.ApplyEnv([]string{"WITTY_SCROLLBACK_MAX_LINES=many"})
+	_, err = f.Section("scrollback", &testSection{})
+	assert.NotNil(t, err)
+}
+
+func TestLayered(t *testing.T) {
+	dir := t.TempDir()
+	repo := NewRepository(dir)
+	assert.Nil(t, repo.Store("test", TestConfig{Hello: "world", N: 1}))
+	f, err := LoadFile(writeFile(t, "[test]\nn = 2\n[other]\nhello = \"file\"\n"), "", []string{"test", "other", "none"})
+	assert.Nil(t, err)
+	layered := NewLayered(repo, f)
+
+	var c TestConfig
+	assert.Nil(t, layered.Load("test", &c))
+	assert.Equal(t, TestConfig{Hello: "world", N: 2}, c)
+	c = TestConfig{}
+	assert.Nil(t, layered.Load("other", &c))
+	assert.Equal(t, TestConfig{Hello: "file"}, c)
+	assert.Error(t, layered.Load("none", &c))
+}
@@ -0,0 +1,13 @@
+package config
+
+import "os"
+
+// lockFile does nothing on Windows, where witty does not run: writes are still atomic, but concurrent
+// updates may be lost.
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// envelope is the format configurations are stored in: the configuration along with the version of its
+// schema. Files written before versions were introduced, or by hand, hold the bare configuration, which is
+// version 1.
+type envelope struct {
+	SchemaVersion int
+	Config        json.RawMessage
+}
+
+// Migration upgrades a stored configuration from a version of its schema to the next one.
+type Migration func(config json.RawMessage) (json.RawMessage, error)
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[string][]Migration{}
+)
+
+// RegisterMigration registers the next migration of the configuration with the given name: the first
+// migration registered upgrades version 1 to version 2, and so on. Packages register the migrations of the
+// configurations they own in their init functions, whenever they change a stored struct in a way that
+// decoding older files would get wrong.
+func RegisterMigration(name string, migration Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations[name] = append(migrations[name], migration)
+}
+
+// unregisterMigrations removes the migrations of the configuration with the given name, for tests.
+func unregisterMigrations(name string) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	delete(migrations, name)
+}
+
+// SchemaVersion returns the current version of the schema of the configuration with the given name.
+func SchemaVersion(name string) int {
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+	return len(migrations[name]) + 1
+}
+
+// unwrap returns the schema version and the content of a stored configuration.
+func unwrap(data []byte) (int, json.RawMessage) {
+	var e envelope
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&e); err == nil && e.SchemaVersion > 0 && e.Config != nil {
+		return e.SchemaVersion, e.Config
+	}
+	return 1, data
+}
+
+// migrate upgrades a configuration of the given version to the current version of its schema.
+func migrate(name string, version int, content json.RawMessage) (json.RawMessage, error) {
+	migrationsMu.RLock()
+	steps := migrations[name]
+	migrationsMu.RUnlock()
+
+	if version > len(steps)+1 {
+		return nil, fmt.Errorf("schema version %d was written by a newer version of witty", version)
+	}
+	for v := version; v <= len(steps); v++ {
+		var err error
+		content, err = steps[v-1](content)
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", v, err)
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+type renamed struct {
+	Name  string
+	Count int
+}
+
+func TestMigrations(t *testing.T) {
+	dir := t.TempDir()
+	repo := NewRepository(dir)
+	// A file written before versions were introduced, with the field later renamed to Name.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "migrated.json"), []byte(`{"Title":"old"}`), 0600))
+
+	t.Cleanup(func() { unregisterMigrations("migrated") })
+	RegisterMigration("migrated", func(content json.RawMessage) (json.RawMessage, error) {
+		var v1 map[string]interface{}
+		if err := json.Unmarshal(content, &v1); err != nil {
+			return nil, err
+		}
+		v1["Name"] = v1["Title"]
+		delete(v1, "Title")
+		return json.Marshal(v1)
+	})
+	RegisterMigration("migrated", func(content json.RawMessage) (json.RawMessage, error) {
+		var v2 renamed
+		if err := json.Unmarshal(content, &v2); err != nil {
+			return nil, err
+		}
+		v2.Count = 1
+		return json.Marshal(v2)
+	})
+	assert.Equal(t, 3, SchemaVersion("migrated"))
+	assert.Equal(t, 1, SchemaVersion("unversioned"))
+
+	var c renamed
+	assert.Nil(t, repo.Load("migrated", &c))
+	assert.Equal(t, renamed{Name: "old", Count: 1}, c)
+
+	// Stored configurations carry the current version and are not migrated again.
+	c.Count = 5
+	assert.Nil(t, repo.Store("migrated", c))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "migrated.json"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(data), `{"SchemaVersion":3,"Config":`))
+	c = renamed{}
+	assert.Nil(t, repo.Load("migrated", &c))
+	assert.Equal(t, renamed{Name: "old", Count: 5}, c)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "migrated.json"), []byte(`{"SchemaVersion":4,"Config":{}}`), 0600))
+	assert.NotNil(t, repo.Load("migrated", &c))
+}
+
+func TestListExists(t *testing.T) {
+	repo := NewRepository(t.TempDir())
+	names, err := repo.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(names))
+
+	assert.Nil(t, repo.Store("b", TestConfig{}))
+	assert.Nil(t, repo.Store("a", TestConfig{}))
+	names, err = repo.List()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+	exists, err := repo.Exists("a")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	exists, err = repo.Exists("c")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
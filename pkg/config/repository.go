@@ -1,13 +1,23 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // Repository is a configuration repository. It stores and loads configuration
 // in a configurable base directory.
+//
+// Writes are atomic: configurations are written to a temporary file which is synced and renamed over the
+// previous one, so that a crash never leaves a configuration truncated. Writers of the same configuration,
+// in this process or others, are serialized with an advisory lock.
 type Repository struct {
 	directory string
 }
@@ -17,49 +27,187 @@ func NewRepository(directory string) *Repository {
 	return &Repository{directory: directory}
 }
 
+func (r *Repository) path(name string) string {
+	return filepath.Join(r.directory, name+".json")
+}
+
 // Store stores the provided configuration object in the repository, with the given name,
 // The object supplied must be json-serializable.
 func (r *Repository) Store(name string, config interface{}) error {
-	// We serialize the object as a json file named 'name.json' in the repository
-	// directory.
+	unlock, err := r.lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return r.write(name, config)
+}
+
+// Load loads the configuration object with the given name from the repository, migrating it to the
+// current version of its schema. The object supplied must be json-deserializable.
+func (r *Repository) Load(name string, config interface{}) error {
+	// We deserialize the object from a json file named 'name.json' in the repository
+	// directory. Writes replace the file atomically, so no lock is needed to read it.
+	data, err := ioutil.ReadFile(r.path(name))
+	if err != nil {
+		return err
+	}
+	version, content := unwrap(data)
+	content, err = migrate(name, version, content)
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", name, err)
+	}
+	return json.Unmarshal(content, config)
+}
+
+// Exists reports whether a configuration object with the given name is stored in the repository.
+func (r *Repository) Exists(name string) (bool, error) {
+	_, err := os.Stat(r.path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
 
-	makeDirIfNotExists(r.directory)
+// List returns the names of the configuration objects stored in the repository, sorted.
+func (r *Repository) List() ([]string, error) {
+	files, err := ioutil.ReadDir(r.directory)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		// Lock and temporary files are hidden.
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(f.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
 
-	file, err := os.OpenFile(r.directory+"/"+name+".json", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+// Update loads the configuration object with the given name, lets modify change it and stores it, holding
+// the lock of the configuration throughout, so that concurrent updates are not lost. config keeps its
+// content if the configuration is not stored yet. Nothing is stored if modify returns an error.
+func (r *Repository) Update(name string, config interface{}, modify func() error) error {
+	unlock, err := r.lock(name)
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	defer file.Close()
+	if err := r.Load(name, config); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := modify(); err != nil {
+		return err
+	}
+	return r.write(name, config)
+}
 
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(config)
+// Delete deletes the configuration object with the given name from the repository.
+// Deleting a configuration that is not stored is not an error.
+func (r *Repository) Delete(name string) error {
+	unlock, err := r.lock(name)
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	return nil
+	err = os.Remove(r.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
 
+// lock takes the lock of the configuration with the given name and returns the function releasing it.
+func (r *Repository) lock(name string) (func(), error) {
+	if err := makeDirIfNotExists(r.directory); err != nil {
+		return nil, err
+	}
+	// The lock is taken on a file of its own: the configuration file is replaced on every write.
+	return Lock(filepath.Join(r.directory, "."+name+".lock"))
 }
 
-// Load loads the configuration object with the given name from the repository.
-// The object supplied must be json-deserializable.
-func (r *Repository) Load(name string, config interface{}) error {
-	// We deserialize the object from a json file named 'name.json' in the repository
+// write writes the configuration in its schema version envelope.
+func (r *Repository) write(name string, config interface{}) error {
+	// We serialize the object as a json file named 'name.json' in the repository
 	// directory.
-	file, err := os.Open(r.directory + "/" + name + ".json")
+	content, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	var data bytes.Buffer
+	if err := json.NewEncoder(&data).Encode(envelope{SchemaVersion: SchemaVersion(name), Config: content}); err != nil {
+		return err
+	}
+	return WriteFile(r.path(name), data.Bytes())
+}
+
+// Lock takes an exclusive advisory lock on the lock file at path, creating it, and returns the function
+// releasing it. Locks serialize writers in this process and in others.
+func Lock(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(file)
+		file.Close()
+	}, nil
+}
 
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(config)
+// WriteFile writes data to a temporary file readable by the user only, which is synced and renamed over the
+// file at path, so that a crash never leaves the file truncated.
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp*")
 	if err != nil {
 		return err
 	}
+	committed := false
+	defer func() {
+		if !committed {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
 
-	return nil
+	if err := file.Chmod(0600); err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+	committed = true
+	return syncDir(dir)
+}
+
+// syncDir makes a rename in the directory durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
 func makeDirIfNotExists(dir string) error {
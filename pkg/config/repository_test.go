@@ -1,8 +1,15 @@
 package config
 
 import (
-	"github.com/autarch/testify/assert"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/autarch/testify/assert"
 )
 
 type TestConfig struct {
@@ -39,3 +46,114 @@ func TestRepository(t *testing.T) {
 	assert.Error(t, err)
 
 }
+
+type counter struct {
+	N int
+	// Padding makes writes long enough to be interleaved if they were not atomic.
+	Padding string
+}
+
+const updatesPerWorker = 50
+
+func increment(repo *Repository) error {
+	for i := 0; i < updatesPerWorker; i++ {
+		var c counter
+		err := repo.Update("counter", &c, func() error {
+			c.N++
+			c.Padding = strings.Repeat("x", 4096+c.N)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRepositoryConcurrentUpdates(t *testing.T) {
+	repo := NewRepository(t.TempDir())
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- increment(repo)
+		}()
+	}
+	// Readers never see a partial write.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			var c counter
+			if err := repo.Load("counter", &c); err != nil && !os.IsNotExist(err) {
+				t.Errorf("read a partial write: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+	<-done
+	close(errs)
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+
+	var c counter
+	assert.Nil(t, repo.Load("counter", &c))
+	assert.Equal(t, workers*updatesPerWorker, c.N)
+}
+
+// TestRepositoryHelperProcess increments the counter of the repository given in the environment, when run
+// by TestRepositoryConcurrentProcesses.
+func TestRepositoryHelperProcess(t *testing.T) {
+	dir := os.Getenv("WITTY_TEST_REPOSITORY")
+	if dir == "" {
+		t.Skip("run by TestRepositoryConcurrentProcesses")
+	}
+	if err := increment(NewRepository(dir)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepositoryConcurrentProcesses(t *testing.T) {
+	dir := t.TempDir()
+	const processes = 4
+	var commands []*exec.Cmd
+	for i := 0; i < processes; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRepositoryHelperProcess$")
+		cmd.Env = append(os.Environ(), "WITTY_TEST_REPOSITORY="+dir)
+		assert.Nil(t, cmd.Start())
+		commands = append(commands, cmd)
+	}
+	for _, cmd := range commands {
+		assert.Nil(t, cmd.Wait())
+	}
+
+	var c counter
+	assert.Nil(t, NewRepository(dir).Load("counter", &c))
+	assert.Equal(t, processes*updatesPerWorker, c.N)
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	for _, f := range files {
+		assert.False(t, strings.Contains(f.Name(), ".tmp"), "temporary file %s left behind", f.Name())
+	}
+}
+
+func TestRepositoryUpdateAbort(t *testing.T) {
+	repo := NewRepository(t.TempDir())
+	assert.Nil(t, repo.Store("counter", counter{N: 1}))
+	var c counter
+	err := repo.Update("counter", &c, func() error {
+		c.N = 2
+		return errors.New("abort")
+	})
+	assert.NotNil(t, err)
+	c = counter{}
+	assert.Nil(t, repo.Load("counter", &c))
+	assert.Equal(t, 1, c.N)
+
+	assert.Nil(t, repo.Delete("counter"))
+	assert.True(t, os.IsNotExist(repo.Load("counter", &c)))
+}
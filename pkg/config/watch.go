@@ -0,0 +1,92 @@
+package config
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// settleTime is how long to wait for more changes before reporting them, as saving a file often takes
+// several writes and renames.
+const settleTime = 100 * time.Millisecond
+
+// Watch watches the configuration in dir, config.toml and the configurations of the repository with the given
+// sections, and calls changed after their content changed. Other files, such as the ones witty writes itself,
+// are not watched. Changes are noticed with inotify on Linux, and by checking the files
+// every interval elsewhere or when inotify is not available. Writes that leave the content unchanged are not
+// reported. Watching stops when stop is called.
+func Watch(dir string, sections []string, interval time.Duration, changed func()) (stop func()) {
+	done := make(chan struct{})
+	events, err := notify(dir, done)
+	if err != nil {
+		log.Debug().Msgf("polling configuration changes: %v", err)
+		events = nil
+	}
+	go watch(dir, sections, snapshot(dir, sections), interval, events, done, changed)
+	return func() { close(done) }
+}
+
+// watch calls changed when the content of the configuration differs from the last one after an event, or on
+// every interval without events.
+func watch(dir string, sections []string, last map[string][sha256.Size]byte, interval time.Duration,
+	events <-chan struct{}, done <-chan struct{}, changed func()) {
+	var poll <-chan time.Time
+	if events == nil {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	settle := time.NewTimer(settleTime)
+	settle.Stop()
+	defer settle.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-events:
+			settle.Reset(settleTime)
+			continue
+		case <-settle.C:
+		case <-poll:
+		}
+		current := snapshot(dir, sections)
+		if !equal(current, last) {
+			last = current
+			changed()
+		}
+	}
+}
+
+// snapshot returns the digests of config.toml and of the configurations with the given sections in dir.
+// Missing files are left out.
+func snapshot(dir string, sections []string) map[string][sha256.Size]byte {
+	digests := map[string][sha256.Size]byte{}
+	names := []string{"config.toml"}
+	for _, section := range sections {
+		names = append(names, section+".json")
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		digests[name] = sha256.Sum256(data)
+	}
+	return digests
+}
+
+func equal(a, b map[string][sha256.Size]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, digest := range a {
+		if other, ok := b[name]; !ok || other != digest {
+			return false
+		}
+	}
+	return true
+}
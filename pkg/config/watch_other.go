@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package config
+
+import "errors"
+
+// notify is not implemented beside Linux: the configuration is polled instead.
+func notify(dir string, done <-chan struct{}) (<-chan struct{}, error) {
+	return nil, errors.New("inotify is only available on Linux")
+}
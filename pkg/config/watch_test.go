@@ -0,0 +1,52 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func waitChange(t *testing.T, changes <-chan struct{}, expected bool, message string) {
+	select {
+	case <-changes:
+		assert.True(t, expected, message)
+	case <-time.After(time.Second):
+		assert.False(t, expected, message)
+	}
+}
+
+func testWatch(t *testing.T, start func(dir string, changed func()) func()) {
+	dir := t.TempDir()
+	repo := NewRepository(dir)
+	assert.Nil(t, repo.Store("trigger", map[string]int{"n": 1}))
+
+	changes := make(chan struct{}, 10)
+	stop := start(dir, func() { changes <- struct{}{} })
+	defer stop()
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte("color = 'red'\n"), 0o600))
+	waitChange(t, changes, true, "config.toml written")
+	assert.Nil(t, repo.Store("trigger", map[string]int{"n": 2}))
+	waitChange(t, changes, true, "configuration replaced")
+	assert.Nil(t, repo.Store("trigger", map[string]int{"n": 2}))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "analytics.jsonl"), []byte("{}\n"), 0o600))
+	assert.Nil(t, repo.Store("trusted-projects", map[string]string{"/a": "b"}))
+	waitChange(t, changes, false, "same content and other files are not reported")
+}
+
+func TestWatch(t *testing.T) {
+	testWatch(t, func(dir string, changed func()) func() {
+		return Watch(dir, []string{"trigger"}, 10*time.Millisecond, changed)
+	})
+}
+
+func TestWatchPolling(t *testing.T) {
+	testWatch(t, func(dir string, changed func()) func() {
+		done := make(chan struct{})
+		go watch(dir, []string{"trigger"}, snapshot(dir, []string{"trigger"}), 10*time.Millisecond, nil, done, changed)
+		return func() { close(done) }
+	})
+}
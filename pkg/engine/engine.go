@@ -4,7 +4,24 @@ type Suggestion interface {
 	Text() string
 }
 
+// Request describes the context a suggestion is requested for.
+type Request struct {
+	// Prompt is the terminal content before the cursor.
+	Prompt string
+	// Suffix is the text after the cursor on the current input line. Engines that support
+	// fill-in-the-middle use it as right context, the others ignore it.
+	Suffix string
+	// Language is the language of the program running in the foreground of the terminal.
+	Language Language
+}
+
 type SuggestionEngine interface {
-	Suggest(prompt string) (Suggestion, error)
-	TopSuggestions(prompt string, current Suggestion) ([]Suggestion, error)
+	Suggest(request Request) (Suggestion, error)
+	TopSuggestions(request Request, current Suggestion) ([]Suggestion, error)
+}
+
+// Authenticator is implemented by engines that can interactively log in again once their credentials
+// are no longer valid. Login is called with the terminal restored to its normal mode.
+type Authenticator interface {
+	Login() error
 }
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kinds of engine errors. Engines wrap the errors of the services they talk to in an *Error of one of
+// these kinds, so that callers can react with errors.Is without knowing about the underlying service.
+var (
+	// ErrUnauthenticated is returned when the engine credentials are missing, invalid or expired and
+	// could not be refreshed.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrRateLimited is returned when the service throttled the request. The request can be retried later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrQuotaExceeded is returned when the account has no quota left. Retrying will not help.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrInvalidRequest is returned when the service rejected the request, for instance because the
+	// prompt is too long.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnavailable is returned when the service could not be reached or failed to answer properly.
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// Error is an engine error of one of the kinds above.
+type Error struct {
+	// Kind is the kind of error, one of the Err* variables of this package.
+	Kind error
+	// RetryAfter is how long the service asked to wait before retrying, when known.
+	RetryAfter time.Duration
+	// Err is the underlying error.
+	Err error
+}
+
+// NewError returns an error of the given kind wrapping err.
+func NewError(kind error, err error) *Error {
+	return &Error{Kind: kind, Err: err}
+}
+
+// NewRateLimitedError returns an ErrRateLimited error wrapping err. retryAfter is zero when unknown.
+func NewRateLimitedError(retryAfter time.Duration, err error) *Error {
+	return &Error{Kind: ErrRateLimited, RetryAfter: retryAfter, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Kind.Error()
+	}
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s (retry after %s): %s", e.Kind, e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the given kind.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// RetryAfter returns how long the service asked to wait before retrying after err, if it did.
+func RetryAfter(err error) (time.Duration, bool) {
+	var engineErr *Error
+	if errors.As(err, &engineErr) && engineErr.RetryAfter > 0 {
+		return engineErr.RetryAfter, true
+	}
+	return 0, false
+}
@@ -0,0 +1,16 @@
+package engine
+
+// Reference points to open source code a suggestion may have been derived from.
+type Reference struct {
+	LicenseName string
+	Repository  string
+	URL         string
+}
+
+// Inspectable is implemented by suggestions that can expose engine specific details for debugging.
+type Inspectable interface {
+	// References returns the code references attached to the suggestion by the engine, if any.
+	References() []Reference
+	// RawResponse returns the engine response the suggestion was built from.
+	RawResponse() interface{}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import "strings"
+
+// Language identifies the language spoken by the program in the foreground of the terminal,
+// along with a file name that engines can use to hint it.
+type Language struct {
+	Name     string
+	FileName string
+}
+
+// Shell is the language used when the shell itself is in the foreground or the program is unknown.
+var Shell = Language{Name: "shell", FileName: "script.sh"}
+
+// LanguageMap maps foreground program names to languages.
+type LanguageMap map[string]Language
+
+// DefaultLanguages returns the built-in mapping of common REPLs to languages.
+func DefaultLanguages() LanguageMap {
+	python := Language{Name: "python", FileName: "script.py"}
+	sql := Language{Name: "sql", FileName: "query.sql"}
+	javascript := Language{Name: "javascript", FileName: "script.js"}
+	ruby := Language{Name: "ruby", FileName: "script.rb"}
+	return LanguageMap{
+		"python":  python,
+		"ipython": python,
+		"psql":    sql,
+		"mysql":   sql,
+		"sqlite":  sql,
+		"node":    javascript,
+		"irb":     ruby,
+		"ruby":    ruby,
+		"sh":      Shell,
+		"bash":    Shell,
+		"zsh":     Shell,
+		"fish":    Shell,
+	}
+}
+
+// Merge returns a copy of the map with the entries of other added to it. Entries in other take precedence.
+func (m LanguageMap) Merge(other LanguageMap) LanguageMap {
+	merged := make(LanguageMap, len(m)+len(other))
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Lookup returns the language for the given program name. Version suffixes such as the ones in
+// python3 or python3.11 are ignored when there is no exact match. Unknown programs are mapped to Shell.
+func (m LanguageMap) Lookup(program string) (Language, bool) {
+	if language, ok := m[program]; ok {
+		return language, true
+	}
+	if language, ok := m[strings.TrimRight(program, "0123456789.")]; ok {
+		return language, true
+	}
+	return Shell, false
+}
@@ -0,0 +1,28 @@
+package engine
+
+import (
+	"github.com/autarch/testify/assert"
+	"testing"
+)
+
+func TestLanguageLookup(t *testing.T) {
+	languages := DefaultLanguages().Merge(LanguageMap{
+		"clojure": {Name: "clojure", FileName: "repl.clj"},
+		"node":    {Name: "typescript", FileName: "script.ts"},
+	})
+
+	language, ok := languages.Lookup("python3.11")
+	assert.True(t, ok)
+	assert.Equal(t, "python", language.Name)
+
+	language, ok = languages.Lookup("clojure")
+	assert.True(t, ok)
+	assert.Equal(t, "repl.clj", language.FileName)
+
+	language, _ = languages.Lookup("node")
+	assert.Equal(t, "typescript", language.Name)
+
+	language, ok = languages.Lookup("htop")
+	assert.False(t, ok)
+	assert.Equal(t, Shell, language)
+}
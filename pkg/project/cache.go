@@ -0,0 +1,59 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache looks up project configuration files like Lookup, but only reads the files that changed since the
+// previous lookup: witty looks them up before every suggestion request.
+type Cache struct {
+	mu    sync.Mutex
+	files map[string]cachedFile
+}
+
+// cachedFile is a file read by a Cache, along with what identifies its version.
+type cachedFile struct {
+	modTime time.Time
+	size    int64
+	file    File
+	err     error
+}
+
+// NewCache creates an empty cache.
+func NewCache() *Cache {
+	return &Cache{files: map[string]cachedFile{}}
+}
+
+// Lookup returns the project configuration files of dir and of its ancestors, outermost first. A file is read
+// again once its modification time or size changes.
+func (c *Cache) Lookup(dir string) ([]File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var files []File
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, FileName)
+		info, err := os.Stat(path)
+		if err == nil {
+			cached, ok := c.files[path]
+			if !ok || !cached.modTime.Equal(info.ModTime()) || cached.size != info.Size() {
+				cached = cachedFile{modTime: info.ModTime(), size: info.Size()}
+				cached.file, cached.err = Read(path)
+				c.files[path] = cached
+			}
+			if cached.err != nil {
+				return nil, cached.err
+			}
+			files = append([]File{cached.file}, files...)
+		} else if os.IsNotExist(err) {
+			delete(c.files, path)
+		} else {
+			return nil, err
+		}
+		if dir == filepath.Dir(dir) {
+			return files, nil
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Package project reads the per-directory configuration of witty: .witty.toml files in the working directory
+// of the shell and in its ancestors, usually checked into the repositories they configure.
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+)
+
+// FileName is the name of project configuration files.
+const FileName = ".witty.toml"
+
+// Config is the content of a project configuration file.
+type Config struct {
+	// Enabled turns suggestions off, or back on, in the directory tree of the file.
+	Enabled *bool `toml:"enabled"`
+	// Engine selects the engine suggestions are requested from.
+	Engine string `toml:"engine"`
+	// Preamble is sent at the beginning of every prompt, to tell the engine about the project.
+	Preamble string `toml:"preamble"`
+	// Redact lists regular expressions whose matches are replaced with [REDACTED] before prompts are sent.
+	Redact []string `toml:"redact"`
+}
+
+// File is a project configuration file.
+type File struct {
+	Path string
+	// Digest identifies the content of the file, so that a trusted file has to be trusted again once changed.
+	Digest string
+	Config Config
+	redact Rules
+}
+
+// Read reads the project configuration file at path. Unknown keys and invalid expressions are errors, as a
+// misspelled redaction rule would silently let through what it was meant to hide.
+func Read(path string) (File, error) {
+	f := File{Path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	sum := sha256.Sum256(data)
+	f.Digest = hex.EncodeToString(sum[:])
+
+	meta, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&f.Config)
+	if err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return f, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+	}
+	f.redact, err = CompileRules(f.Config.Redact)
+	if err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Sensitive returns the keys of the file that change what is sent to an engine, or to which one: they only
+// apply once the user trusts the file, as anyone can commit a .witty.toml to a repository. Disabling
+// suggestions and redaction rules only restrict what is sent, and apply regardless.
+func (f File) Sensitive() []string {
+	var keys []string
+	if f.Config.Enabled != nil && *f.Config.Enabled {
+		keys = append(keys, "enabled")
+	}
+	if f.Config.Engine != "" {
+		keys = append(keys, "engine")
+	}
+	if f.Config.Preamble != "" {
+		keys = append(keys, "preamble")
+	}
+	return keys
+}
+
+// Lookup reads the project configuration files of dir and of its ancestors, outermost first.
+func Lookup(dir string) ([]File, error) {
+	var files []File
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		f, err := Read(filepath.Join(dir, FileName))
+		if err == nil {
+			files = append([]File{f}, files...)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if dir == filepath.Dir(dir) {
+			return files, nil
+		}
+	}
+}
+
+// Project is the configuration in effect in a directory.
+type Project struct {
+	Enabled  bool
+	Engine   string
+	Preamble string
+	redact   Rules
+	// Untrusted lists the files whose sensitive settings were not applied because they are not trusted.
+	Untrusted []File
+}
+
+// Resolve merges the configuration files returned by Lookup, the settings of nearer files overriding those of
+// outer ones. Redaction rules add up. Sensitive settings are only taken from the files trusted reports as such.
+func Resolve(files []File, trusted func(File) bool) Project {
+	p := Project{Enabled: true}
+	for _, f := range files {
+		p.redact = append(p.redact, f.redact...)
+		if f.Config.Enabled != nil && !*f.Config.Enabled {
+			p.Enabled = false
+		}
+		if len(f.Sensitive()) == 0 {
+			continue
+		}
+		if !trusted(f) {
+			p.Untrusted = append(p.Untrusted, f)
+			continue
+		}
+		if f.Config.Enabled != nil {
+			p.Enabled = true
+		}
+		if f.Config.Engine != "" {
+			p.Engine = f.Config.Engine
+		}
+		if f.Config.Preamble != "" {
+			p.Preamble = f.Config.Preamble
+		}
+	}
+	return p
+}
+
+// Redact replaces the matches of the redaction rules of the project in text.
+func (p Project) Redact(text string) string {
+	return p.redact.Redact(text)
+}
+
+// Rules are redaction rules: regular expressions whose matches are hidden from engines.
+type Rules []*regexp.Regexp
+
+// CompileRules compiles the given redaction rules.
+func CompileRules(exprs []string) (Rules, error) {
+	var rules Rules
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule: %w", err)
+		}
+		rules = append(rules, re)
+	}
+	return rules, nil
+}
+
+// Redact replaces the matches of the rules in text with [REDACTED].
+func (r Rules) Redact(text string) string {
+	for _, re := range r {
+		text = re.ReplaceAllString(text, "[REDACTED]")
+	}
+	return text
+}
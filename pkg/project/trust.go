@@ -0,0 +1,66 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// trustName is the name the trusted files are stored under in the configuration repository.
+const trustName = "trusted-projects"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+	Update(name string, config interface{}, modify func() error) error
+}
+
+// Trust keeps the project configuration files the user trusts, with the digest of the content they trusted.
+type Trust struct {
+	repo  configRepository
+	mu    sync.Mutex
+	files map[string]string
+}
+
+// LoadTrust loads the trusted files from the configuration repository.
+func LoadTrust(repo configRepository) (*Trust, error) {
+	t := &Trust{repo: repo, files: map[string]string{}}
+	if err := repo.Load(trustName, &t.files); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load trusted projects: %w", err)
+	}
+	return t, nil
+}
+
+// Reload loads the trusted files again, to see the ones trusted by other processes.
+func (t *Trust) Reload() error {
+	files := map[string]string{}
+	if err := t.repo.Load(trustName, &files); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to load trusted projects: %w", err)
+	}
+	t.mu.Lock()
+	t.files = files
+	t.mu.Unlock()
+	return nil
+}
+
+// Trusted reports whether the user trusts the file as it is now.
+func (t *Trust) Trusted(f File) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.files[f.Path] == f.Digest
+}
+
+// Allow records that the user trusts the file.
+func (t *Trust) Allow(f File) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := map[string]string{}
+	err := t.repo.Update(trustName, &files, func() error {
+		files[f.Path] = f.Digest
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	t.files = files
+	return nil
+}
@@ -0,0 +1,55 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Budget limits the size of the prompt sent to an engine.
+type Budget struct {
+	// MaxTokens is the number of prompt tokens the engine accepts, leaving room for the completion.
+	MaxTokens int
+	// MaxOutputLines is the number of lines of a command output kept before it is truncated in the middle.
+	// Zero means outputs are only truncated when they do not fit MaxTokens.
+	MaxOutputLines int
+}
+
+// DefaultBudgets returns the budgets of the built-in engines.
+func DefaultBudgets() map[string]Budget {
+	return map[string]Budget{
+		// gpt-3.5-turbo-instruct has a 4096 tokens context, shared with the completion and the suffix.
+		"gpt3.5": {MaxTokens: 3500, MaxOutputLines: 50},
+		// CodeWhisperer accepts up to 10240 characters of left context.
+		"codewhisperer": {MaxTokens: 2500, MaxOutputLines: 50},
+	}
+}
+
+// budgetsName is the name the budgets are stored under in the configuration repository.
+const budgetsName = "prompt"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+}
+
+// LoadBudget loads the budget of the given engine from the configuration repository, where budgets are
+// stored by engine name. Engines without a stored budget use their default one.
+func LoadBudget(configRepository configRepository, engineName string) (Budget, error) {
+	stored := map[string]json.RawMessage{}
+	err := configRepository.Load(budgetsName, &stored)
+	if err != nil && !os.IsNotExist(err) {
+		return Budget{}, fmt.Errorf("failed to load prompt budgets: %w", err)
+	}
+	budget, ok := DefaultBudgets()[engineName]
+	if raw, found := stored[engineName]; found {
+		// Settings missing from the stored budget keep their default value.
+		if err := json.Unmarshal(raw, &budget); err != nil {
+			return Budget{}, fmt.Errorf("invalid prompt budget for engine %s: %w", engineName, err)
+		}
+		ok = true
+	}
+	if !ok {
+		return Budget{}, fmt.Errorf("no prompt budget for engine %s", engineName)
+	}
+	return budget, nil
+}
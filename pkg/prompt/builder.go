@@ -0,0 +1,215 @@
+// Package prompt builds the prompts sent to engines from the terminal content, keeping them within the
+// number of tokens the engine accepts.
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Builder fits the terminal content into a token budget. The current line is always kept; earlier content
+// is split into commands with their outputs and the most recent commands are kept first. Outputs that are
+// too long are truncated in the middle, as their beginning and end are usually the most relevant parts.
+type Builder struct {
+	tokenizer Tokenizer
+	budget    Budget
+}
+
+// NewBuilder creates a prompt builder counting tokens with the given tokenizer.
+func NewBuilder(tokenizer Tokenizer, budget Budget) *Builder {
+	return &Builder{tokenizer: tokenizer, budget: budget}
+}
+
+// promptMarkers end the prompts of common shells and REPLs.
+var promptMarkers = []string{"$ ", "# ", "% ", "> ", "❯ "}
+
+// block is a command line followed by its output. The block of the content preceding the first command
+// has no command line. Outputs truncated in the middle keep the lines around the cut, where a note says how
+// many lines were omitted.
+type block struct {
+	command    string
+	hasCommand bool
+	output     []string
+	cut        int
+	omitted    int
+}
+
+func (b block) lines() []string {
+	var lines []string
+	if b.hasCommand {
+		lines = append(lines, b.command)
+	}
+	if b.omitted == 0 {
+		return append(lines, b.output...)
+	}
+	lines = append(lines, b.output[:b.cut]...)
+	lines = append(lines, fmt.Sprintf("[... %d lines omitted ...]", b.omitted))
+	return append(lines, b.output[b.cut:]...)
+}
+
+// truncateMiddle keeps the given number of lines of output, a third from its beginning and the rest from its
+// end.
+func (b block) truncateMiddle(keep int) block {
+	if len(b.output) <= keep {
+		return b
+	}
+	head := keep / 3
+	tail := keep - head
+	truncated := b
+	truncated.output = append(append([]string{}, b.output[:head]...), b.output[len(b.output)-tail:]...)
+	truncated.cut = head
+	truncated.omitted = b.omitted + len(b.output) - keep
+	return truncated
+}
+
+// truncateHead keeps the given number of lines at the end of output.
+func (b block) truncateHead(keep int) block {
+	dropped := len(b.output) - keep
+	truncated := b
+	truncated.output = b.output[dropped:]
+	truncated.cut = b.cut - dropped
+	if truncated.cut <= 0 {
+		// The note is dropped along with the lines before it.
+		truncated.cut, truncated.omitted = 0, 0
+	}
+	return truncated
+}
+
+// Build returns the part of text that fits the budget. The last line of text is the line being edited, of
+// which only the end is kept if it does not fit by itself. The budget is shared with the other texts sent
+// along with the prompt, such as a preamble or the text after the cursor.
+func (b *Builder) Build(text string, others ...string) string {
+	lines := strings.Split(text, "\n")
+	current := lines[len(lines)-1]
+	budget := b.budget.MaxTokens
+	for _, other := range others {
+		if other != "" {
+			budget -= b.tokenizer.CountTokens(other + "\n")
+		}
+	}
+	remaining := budget - b.tokenizer.CountTokens(current)
+	if remaining <= 0 {
+		return b.truncateLine(current, budget)
+	}
+
+	blocks := splitCommands(lines[:len(lines)-1], current)
+	var kept [][]string
+	for i := len(blocks) - 1; i >= 0; i-- {
+		blk := blocks[i]
+		if b.budget.MaxOutputLines > 0 {
+			blk = blk.truncateMiddle(b.budget.MaxOutputLines)
+		}
+		cost := b.cost(blk.lines())
+		if cost <= remaining {
+			kept = append(kept, blk.lines())
+			remaining -= cost
+			continue
+		}
+		// Keep as much as fits of the first block that does not, then stop: older commands without the
+		// ones in between would be misleading.
+		if lines := b.fit(blk, remaining); len(lines) > 0 {
+			kept = append(kept, lines)
+		}
+		break
+	}
+
+	var sb strings.Builder
+	for i := len(kept) - 1; i >= 0; i-- {
+		for _, line := range kept[i] {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString(current)
+	return sb.String()
+}
+
+// truncateLine returns the longest end of line that fits in the given number of tokens: the text next to
+// the cursor matters most.
+func (b *Builder) truncateLine(line string, tokens int) string {
+	runes := []rune(line)
+	keep := sort.Search(len(runes)+1, func(n int) bool {
+		return b.tokenizer.CountTokens(string(runes[len(runes)-n:])) > tokens
+	}) - 1
+	if keep < 0 {
+		return ""
+	}
+	return string(runes[len(runes)-keep:])
+}
+
+// cost returns the number of tokens of the given lines, including their line breaks.
+func (b *Builder) cost(lines []string) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	return b.tokenizer.CountTokens(strings.Join(lines, "\n") + "\n")
+}
+
+// fit returns the lines of blk that fit in the given number of tokens. The output of a command is truncated
+// in the middle; content without a command keeps its tail.
+func (b *Builder) fit(blk block, tokens int) []string {
+	truncate := func(keep int) []string {
+		if !blk.hasCommand {
+			return blk.truncateHead(keep).lines()
+		}
+		return blk.truncateMiddle(keep).lines()
+	}
+	// The largest number of output lines that still fits.
+	keep := sort.Search(len(blk.output)+1, func(n int) bool {
+		return b.cost(truncate(n)) > tokens
+	}) - 1
+	if keep < 0 {
+		return nil
+	}
+	return truncate(keep)
+}
+
+// splitCommands splits lines into blocks starting at command lines. Command lines are recognized by the
+// prompt of the current line: its text up to the first prompt marker. As prompts often include the working
+// directory, lines sharing the prompt text before its first colon and containing the marker also match.
+// When the current line has no recognizable prompt, all lines form a single block.
+func splitCommands(lines []string, current string) []block {
+	head, marker := promptHead(current)
+	stem := ""
+	if i := strings.Index(head, ":"); i > 0 {
+		stem = head[:i+1]
+	}
+	isCommand := func(line string) bool {
+		if head == "" {
+			return false
+		}
+		if strings.HasPrefix(line, head) {
+			return true
+		}
+		return stem != "" && strings.HasPrefix(line, stem) && strings.Contains(line, marker)
+	}
+
+	var blocks []block
+	for _, line := range lines {
+		if isCommand(line) {
+			blocks = append(blocks, block{command: line, hasCommand: true})
+			continue
+		}
+		if len(blocks) == 0 {
+			blocks = append(blocks, block{})
+		}
+		last := &blocks[len(blocks)-1]
+		last.output = append(last.output, line)
+	}
+	return blocks
+}
+
+// promptHead returns the prompt of line, up to and including the earliest prompt marker, along with the marker.
+func promptHead(line string) (string, string) {
+	end, marker := -1, ""
+	for _, m := range promptMarkers {
+		if i := strings.Index(line, m); i >= 0 && (end < 0 || i < end) {
+			end, marker = i, m
+		}
+	}
+	if end < 0 {
+		return "", ""
+	}
+	return line[:end+len(marker)], marker
+}
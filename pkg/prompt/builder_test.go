@@ -0,0 +1,95 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+)
+
+// runeTokenizer counts one token per rune, to make budgets easy to reason about.
+type runeTokenizer struct{}
+
+func (runeTokenizer) CountTokens(text string) int {
+	return len([]rune(text))
+}
+
+func TestBuildKeepsEverythingWithinBudget(t *testing.T) {
+	text := "user@host:~$ ls\na b c\nuser@host:~$ git st"
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 100})
+
+	assert.Equal(t, text, builder.Build(text))
+}
+
+func TestBuildKeepsMostRecentCommands(t *testing.T) {
+	text := strings.Join([]string{
+		"user@host:~$ echo first",
+		"first",
+		"user@host:~/src$ echo second",
+		"second",
+		"user@host:~/src$ git ",
+	}, "\n")
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 60})
+
+	assert.Equal(t, "user@host:~/src$ echo second\nsecond\nuser@host:~/src$ git ", builder.Build(text))
+}
+
+func TestBuildTruncatesLongOutputsInTheMiddle(t *testing.T) {
+	lines := []string{"$ seq 1 10"}
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	lines = append(lines, "$ ")
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 1000, MaxOutputLines: 4})
+
+	expected := "$ seq 1 10\n1\n[... 6 lines omitted ...]\n8\n9\n10\n$ "
+	assert.Equal(t, expected, builder.Build(strings.Join(lines, "\n")))
+}
+
+func TestBuildTruncatesToFitBudget(t *testing.T) {
+	lines := []string{"$ seq 1 10"}
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("line %02d", i))
+	}
+	lines = append(lines, "$ ")
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 60})
+
+	expected := "$ seq 1 10\n[... 8 lines omitted ...]\nline 09\nline 10\n$ "
+	assert.Equal(t, expected, builder.Build(strings.Join(lines, "\n")))
+}
+
+func TestBuildKeepsTailWithoutPrompt(t *testing.T) {
+	text := "one\ntwo\nthree\nfour"
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 16})
+
+	assert.Equal(t, "two\nthree\nfour", builder.Build(text))
+}
+
+func TestBuildLeavesRoomForOtherTexts(t *testing.T) {
+	text := "one\ntwo\nthree\nfour"
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 16})
+
+	assert.Equal(t, "three\nfour", builder.Build(text, "pre"))
+	assert.Equal(t, "two\nthree\nfour", builder.Build(text, ""))
+}
+
+func TestBuildKeepsEndOfLongCurrentLine(t *testing.T) {
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 10})
+
+	assert.Equal(t, "0123456789", builder.Build("earlier\n$ echo 0123456789"))
+	assert.Equal(t, "789", builder.Build("$ echo 0123456789", "suffix"))
+}
+
+func TestBuildTruncatesTruncatedOutputToFitBudget(t *testing.T) {
+	lines := []string{"one"}
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("line %02d", i))
+	}
+	lines = append(lines, "last")
+	builder := NewBuilder(runeTokenizer{}, Budget{MaxTokens: 40, MaxOutputLines: 2})
+
+	// Content without a command keeps its tail, within the limit of output lines.
+	expected := "line 09\nline 10\nlast"
+	assert.Equal(t, expected, builder.Build(strings.Join(lines, "\n")))
+}
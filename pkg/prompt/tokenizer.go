@@ -0,0 +1,135 @@
+package prompt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jjviana/codex/pkg/config"
+	"github.com/jjviana/codex/pkg/transport"
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Tokenizer estimates how many tokens a model needs to represent a text.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer estimates token counts from the text length, assuming about four characters per token.
+// It is used for engines whose tokenizer is not known.
+type HeuristicTokenizer struct{}
+
+func (HeuristicTokenizer) CountTokens(text string) int {
+	return (len([]rune(text)) + 3) / 4
+}
+
+// BPETokenizer counts tokens with the byte pair encoding of an OpenAI model. The encoding ranks are
+// downloaded on first use and cached. Until they are available the count is estimated with a
+// HeuristicTokenizer.
+type BPETokenizer struct {
+	mu       sync.Mutex
+	encoding *tiktoken.Tiktoken
+}
+
+// setLoader sets the loader of tiktoken, which is global, once.
+var setLoader sync.Once
+
+// NewBPETokenizer returns a tokenizer for the given OpenAI model, caching encoding ranks in cacheDir and
+// downloading them with the given policy. The encoding is loaded in the background. Encoding ranks are loaded
+// by tiktoken for all tokenizers: the cache directory and policy of the first tokenizer apply.
+func NewBPETokenizer(model string, cacheDir string, policy transport.Policy) *BPETokenizer {
+	t := &BPETokenizer{}
+	setLoader.Do(func() {
+		tiktoken.SetBpeLoader(&cachingBpeLoader{
+			cacheDir: cacheDir,
+			client:   &http.Client{Transport: transport.NewTransport(policy)},
+		})
+	})
+	go func() {
+		encoding, err := tiktoken.EncodingForModel(model)
+		if err != nil {
+			log.Debug().Msgf("failed to load BPE encoding for %s, token counts are estimated: %v", model, err)
+			return
+		}
+		t.mu.Lock()
+		t.encoding = encoding
+		t.mu.Unlock()
+	}()
+	return t
+}
+
+func (t *BPETokenizer) CountTokens(text string) int {
+	t.mu.Lock()
+	encoding := t.encoding
+	t.mu.Unlock()
+	if encoding == nil {
+		return HeuristicTokenizer{}.CountTokens(text)
+	}
+	return len(encoding.EncodeOrdinary(text))
+}
+
+// cachingBpeLoader loads tiktoken encoding ranks, keeping a copy of the downloaded files in a cache directory.
+type cachingBpeLoader struct {
+	cacheDir string
+	client   *http.Client
+}
+
+func (l *cachingBpeLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	cachePath := filepath.Join(l.cacheDir, path.Base(url))
+	contents, err := ioutil.ReadFile(cachePath)
+	if err != nil {
+		contents, err = l.download(url)
+		if err != nil {
+			return nil, err
+		}
+		// Other instances of witty may read the cache meanwhile: it is only visible once complete.
+		if err := os.MkdirAll(l.cacheDir, 0700); err == nil {
+			_ = config.WriteFile(cachePath, contents)
+		}
+	}
+	return parseBpeRanks(contents)
+}
+
+func (l *cachingBpeLoader) download(url string) ([]byte, error) {
+	resp, err := l.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// parseBpeRanks parses a tiktoken file: one base64 encoded token and its rank per line.
+func parseBpeRanks(contents []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid BPE rank line %q", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		rank, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		ranks[string(token)] = rank
+	}
+	return ranks, nil
+}
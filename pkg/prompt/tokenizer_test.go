@@ -0,0 +1,34 @@
+package prompt
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/transport"
+)
+
+func TestCachingBpeLoader(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		_, _ = w.Write([]byte("YQ== 0\nYg== 1\n"))
+	}))
+	defer server.Close()
+	dir := filepath.Join(t.TempDir(), "tiktoken")
+	l := &cachingBpeLoader{cacheDir: dir, client: &http.Client{Transport: transport.NewTransport(transport.DefaultPolicy())}}
+
+	for i := 0; i < 2; i++ {
+		ranks, err := l.LoadTiktokenBpe(server.URL + "/test.tiktoken")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"a": 0, "b": 1}, ranks)
+	}
+	assert.Equal(t, 1, downloads, "the ranks are downloaded once")
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files), "no temporary file is left")
+	assert.Equal(t, "test.tiktoken", files[0].Name())
+}
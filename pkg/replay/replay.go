@@ -0,0 +1,134 @@
+// Package replay evaluates engines offline on recorded sessions. At every point of a recording where a
+// suggestion was shown, engines are asked for a suggestion again and it is compared with what the user
+// actually typed.
+package replay
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/jjviana/codex/pkg/asciicast"
+	"github.com/jjviana/codex/pkg/witty"
+)
+
+// Point is a point of a recording where a suggestion was shown.
+type Point struct {
+	// Time is the number of seconds since the start of the recording.
+	Time float64
+	// Prompt is the terminal content the prompt is built from, and Suffix the text after the cursor.
+	Prompt string
+	Suffix string
+	// Recorded is the suggestion shown in the recording, and Program the program in the foreground then,
+	// empty for the shell or in older recordings.
+	Recorded string
+	Program  string
+	// Typed is what the command line contained from the cursor on when it was submitted, or when the cursor
+	// left it: the suggestion the user would have wanted.
+	Typed string
+
+	x, y int
+}
+
+// Terminal is the headless terminal recordings are played into.
+type Terminal interface {
+	io.Writer
+	Resize(width, height int)
+	Cursor() (int, int)
+	LineSuffix(x, y int) string
+	Prompt() string
+}
+
+// NewTerminal creates a headless terminal of the given size.
+type NewTerminal func(width, height int) (Terminal, error)
+
+// Points plays a recording into a terminal and returns the points where a suggestion was shown.
+func Points(d *asciicast.Decoder, newTerminal NewTerminal) ([]Point, error) {
+	header, err := d.Header()
+	if err != nil {
+		return nil, err
+	}
+	terminal, err := newTerminal(header.Width, header.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []Point
+	// pending are the indexes of the points whose command line was not submitted yet.
+	var pending []int
+	settle := func(submitted bool) {
+		x, y := terminal.Cursor()
+		remaining := pending[:0]
+		for _, i := range pending {
+			p := &points[i]
+			if submitted || y != p.y {
+				continue
+			}
+			p.Typed = terminal.LineSuffix(p.x, p.y)
+			if x < p.x && p.Typed == "" {
+				// The line was erased back past the suggestion point.
+				continue
+			}
+			remaining = append(remaining, i)
+		}
+		pending = remaining
+	}
+
+	for {
+		event, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch event.Type {
+		case asciicast.Output:
+			// Line feeds are written separately: a line feed submits the command line, and the line must be
+			// read before it scrolls away.
+			data := []byte(event.Data)
+			for len(data) > 0 {
+				i := bytes.IndexByte(data, '\n')
+				if i < 0 {
+					i = len(data)
+				}
+				if i > 0 {
+					if _, err := terminal.Write(data[:i]); err != nil {
+						return nil, err
+					}
+					settle(false)
+				}
+				if i < len(data) {
+					settle(true)
+					if _, err := terminal.Write(data[i : i+1]); err != nil {
+						return nil, err
+					}
+					i++
+				}
+				data = data[i:]
+			}
+		case asciicast.Resize:
+			width, height, err := asciicast.ParseSize(event.Data)
+			if err != nil {
+				return nil, err
+			}
+			terminal.Resize(width, height)
+		case asciicast.Marker:
+			program, recorded, ok := witty.ParseShownMarker(event.Data)
+			if !ok {
+				continue
+			}
+			x, y := terminal.Cursor()
+			points = append(points, Point{
+				Time:     event.Time,
+				Prompt:   terminal.Prompt(),
+				Suffix:   terminal.LineSuffix(x, y),
+				Recorded: recorded,
+				Program:  program,
+				x:        x,
+				y:        y,
+			})
+			pending = append(pending, len(points)-1)
+		}
+	}
+	return points, nil
+}
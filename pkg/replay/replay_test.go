@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/asciicast"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/prompt"
+	"github.com/jjviana/codex/pkg/scrollback"
+	"github.com/jjviana/codex/pkg/witty"
+)
+
+func newHeadless(width, height int) (Terminal, error) {
+	return witty.NewHeadless(width, height, scrollback.DefaultConfig())
+}
+
+func TestPoints(t *testing.T) {
+	recording := strings.Join([]string{
+		`{"version":2,"width":40,"height":40}`,
+		`[0.1,"o","$ echo hello\r\nhello\r\n$ git "]`,
+		`[0.2,"m","suggestion shown: status"]`,
+		`[0.3,"o","c"]`,
+		`[0.4,"o","ommit"]`,
+		`[0.5,"m","suggestion shown (git): ommit -a"]`,
+		`[0.6,"o"," -m fix\r\n"]`,
+		`[0.7,"o","$ "]`,
+	}, "\n")
+	points, err := Points(asciicast.NewDecoder(strings.NewReader(recording)), newHeadless)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(points))
+
+	assert.Equal(t, "status", points[0].Recorded)
+	assert.Equal(t, "commit -m fix", points[0].Typed)
+	assert.True(t, strings.HasSuffix(points[0].Prompt, "$ git "))
+	assert.True(t, strings.Contains(points[0].Prompt, "hello"))
+
+	assert.Equal(t, "", points[0].Program)
+	assert.Equal(t, "ommit -a", points[1].Recorded)
+	assert.Equal(t, "git", points[1].Program)
+	assert.Equal(t, " -m fix", points[1].Typed)
+}
+
+func TestScore(t *testing.T) {
+	var result Result
+	result.Add("commit", "commit -m fix")
+	result.Add("commit -m fix\n", "commit -m fix")
+	result.Add("status", "commit -m fix")
+	result.Add("", "ls")
+	assert.Equal(t, 4, result.Points)
+	assert.Equal(t, 2, result.Hits)
+	assert.Equal(t, 1, result.ExactMatches)
+	assert.Equal(t, 1, result.Empty)
+	assert.Equal(t, 0.5, result.HitRate())
+
+	assert.Equal(t, 3, EditDistance("kitten", "sitting"))
+	assert.Equal(t, 2, EditDistance("日本", ""))
+}
+
+type text string
+
+func (t text) Text() string {
+	return string(t)
+}
+
+// scriptedEngine returns its suggestions in turn, failing on empty ones. It keeps the requests it receives.
+type scriptedEngine struct {
+	suggestions []string
+	requests    []engine.Request
+}
+
+func (e *scriptedEngine) Suggest(request engine.Request) (engine.Suggestion, error) {
+	e.requests = append(e.requests, request)
+	s := e.suggestions[0]
+	e.suggestions = e.suggestions[1:]
+	if s == "" {
+		return nil, errors.New("unavailable")
+	}
+	return text(s), nil
+}
+
+func (e *scriptedEngine) TopSuggestions(engine.Request, engine.Suggestion) ([]engine.Suggestion, error) {
+	return nil, nil
+}
+
+func TestEvaluateCountsErrorsSeparately(t *testing.T) {
+	points := []Point{{Typed: "status"}, {Typed: "ls", Program: "python3"}}
+	e := &scriptedEngine{suggestions: []string{"status", ""}}
+	builder := prompt.NewBuilder(prompt.HeuristicTokenizer{}, prompt.Budget{MaxTokens: 100})
+
+	result, suggestions := Evaluate("scripted", e, builder, engine.DefaultLanguages(), points)
+	assert.Equal(t, engine.Shell, e.requests[0].Language)
+	assert.Equal(t, "python", e.requests[1].Language.Name)
+	assert.Equal(t, []string{"status", ""}, suggestions)
+	assert.Equal(t, 1, result.Points)
+	assert.Equal(t, 1, result.Errors)
+	assert.Equal(t, 0, result.Empty)
+	assert.Equal(t, 1.0, result.HitRate())
+}
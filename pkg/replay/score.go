@@ -0,0 +1,144 @@
+package replay
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/prompt"
+)
+
+// Result summarizes how the suggestions of an engine compare with what the user typed.
+type Result struct {
+	Engine string
+	// Points is the number of suggestion points scored and Empty the ones where the engine had no suggestion.
+	// Errors counts the points where the engine failed, which are not scored.
+	Points int
+	Errors int
+	Empty  int
+	// Hits counts the suggestions the user typed, possibly followed by more text, and ExactMatches the ones
+	// that are exactly what the user typed.
+	Hits         int
+	ExactMatches int
+	// EditDistance is the sum of the edit distances between the suggestions and what the user typed.
+	EditDistance int
+	Latencies    []time.Duration
+}
+
+// HitRate returns the fraction of points where the suggestion was a hit.
+func (r Result) HitRate() float64 {
+	return ratio(r.Hits, r.Points)
+}
+
+// ExactMatchRate returns the fraction of points where the suggestion was exactly what the user typed.
+func (r Result) ExactMatchRate() float64 {
+	return ratio(r.ExactMatches, r.Points)
+}
+
+// MeanEditDistance returns the mean edit distance between suggestions and what the user typed.
+func (r Result) MeanEditDistance() float64 {
+	return ratio(r.EditDistance, r.Points)
+}
+
+// MedianLatency returns the median latency of the engine requests.
+func (r Result) MedianLatency() time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	latencies := append([]time.Duration(nil), r.Latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)/2]
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// Add scores a suggestion against what the user typed.
+func (r *Result) Add(suggestion, typed string) {
+	r.Points++
+	suggestion = strings.TrimRight(suggestion, " \n")
+	typed = strings.TrimRight(typed, " \n")
+	if suggestion == "" {
+		r.Empty++
+	} else if strings.HasPrefix(typed, suggestion) {
+		r.Hits++
+	}
+	if suggestion == typed {
+		r.ExactMatches++
+	}
+	r.EditDistance += EditDistance(suggestion, typed)
+}
+
+// Baseline scores the suggestions shown in the recording.
+func Baseline(points []Point) Result {
+	result := Result{Engine: "recorded"}
+	for _, p := range points {
+		result.Add(p.Recorded, p.Typed)
+	}
+	return result
+}
+
+// Evaluate asks the engine for a suggestion at every point, building prompts with the given builder and
+// mapping the programs of the points to languages with languages, and scores them. It returns the
+// suggestions along with the result.
+func Evaluate(name string, e engine.SuggestionEngine, builder *prompt.Builder, languages engine.LanguageMap,
+	points []Point) (Result, []string) {
+	result := Result{Engine: name}
+	suggestions := make([]string, len(points))
+	for i, p := range points {
+		request := engine.Request{
+			Prompt:   builder.Build(p.Prompt, p.Suffix),
+			Suffix:   p.Suffix,
+			Language: engine.Shell,
+		}
+		if p.Program != "" {
+			request.Language, _ = languages.Lookup(p.Program)
+		}
+		start := time.Now()
+		suggestion, err := e.Suggest(request)
+		result.Latencies = append(result.Latencies, time.Since(start))
+		if err != nil {
+			result.Errors++
+			continue
+		}
+		if suggestion != nil {
+			suggestions[i] = suggestion.Text()
+		}
+		result.Add(suggestions[i], p.Typed)
+	}
+	return result, suggestions
+}
+
+// EditDistance returns the Levenshtein distance between a and b, counted in runes.
+func EditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
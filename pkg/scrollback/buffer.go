@@ -0,0 +1,131 @@
+// Package scrollback keeps the lines that scrolled off the terminal screen, along with their attributes.
+package scrollback
+
+import (
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/ActiveState/vt10x"
+)
+
+// Line is a line of the terminal, one glyph per column. Trailing blank cells are not kept.
+type Line []vt10x.Glyph
+
+// String returns the text of the line.
+func (l Line) String() string {
+	var sb strings.Builder
+	for _, g := range l {
+		if g.Char == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		sb.WriteRune(g.Char)
+	}
+	return sb.String()
+}
+
+// glyphSize and lineOverhead estimate the memory used by a line.
+const (
+	glyphSize    = int(unsafe.Sizeof(vt10x.Glyph{}))
+	lineOverhead = int(unsafe.Sizeof(Line{}))
+)
+
+func (l Line) size() int {
+	return lineOverhead + len(l)*glyphSize
+}
+
+// Buffer is a ring buffer of the most recent lines that scrolled off the screen. It is bounded both in
+// number of lines and in memory, the oldest lines being dropped first. It is safe for concurrent use.
+type Buffer struct {
+	mu       sync.Mutex
+	lines    []Line
+	start    int
+	count    int
+	bytes    int
+	maxBytes int
+}
+
+// NewBuffer creates a buffer keeping at most maxLines lines and about maxBytes bytes of them.
+// A zero maxLines disables the buffer. maxLines must not be negative.
+func NewBuffer(maxLines, maxBytes int) *Buffer {
+	return &Buffer{lines: make([]Line, maxLines), maxBytes: maxBytes}
+}
+
+// Push adds a line to the buffer, dropping the oldest lines if it is full.
+func (b *Buffer) Push(glyphs []vt10x.Glyph) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) == 0 {
+		return
+	}
+	line := make(Line, trimmedLength(glyphs))
+	copy(line, glyphs)
+
+	if b.count == len(b.lines) {
+		b.dropOldest()
+	}
+	b.lines[(b.start+b.count)%len(b.lines)] = line
+	b.count++
+	b.bytes += line.size()
+	for b.maxBytes > 0 && b.bytes > b.maxBytes && b.count > 0 {
+		b.dropOldest()
+	}
+}
+
+func (b *Buffer) dropOldest() {
+	b.bytes -= b.lines[b.start].size()
+	b.lines[b.start] = nil
+	b.start = (b.start + 1) % len(b.lines)
+	b.count--
+}
+
+// trimmedLength returns the length of glyphs without its trailing blank cells.
+func trimmedLength(glyphs []vt10x.Glyph) int {
+	n := len(glyphs)
+	for n > 0 {
+		g := glyphs[n-1]
+		blank := g.Char == ' ' || g.Char == 0
+		if !blank || g.BG != vt10x.DefaultBG || g.Mode&(vt10x.AttrReverse|vt10x.AttrUnderline) != 0 {
+			break
+		}
+		n--
+	}
+	return n
+}
+
+// Len returns the number of lines in the buffer.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// Lines returns all the lines of the buffer, oldest first.
+func (b *Buffer) Lines() []Line {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := make([]Line, b.count)
+	for i := range lines {
+		lines[i] = b.lines[(b.start+i)%len(b.lines)]
+	}
+	return lines
+}
+
+// Text returns the text of the last n lines of the buffer, each followed by a line break.
+func (b *Buffer) Text(n int) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if n > b.count {
+		n = b.count
+	}
+	var sb strings.Builder
+	for i := b.count - n; i < b.count; i++ {
+		sb.WriteString(b.lines[(b.start+i)%len(b.lines)].String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
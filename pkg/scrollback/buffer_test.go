@@ -0,0 +1,61 @@
+package scrollback
+
+import (
+	"testing"
+
+	"github.com/ActiveState/vt10x"
+	"github.com/autarch/testify/assert"
+)
+
+func glyphs(text string, width int) []vt10x.Glyph {
+	line := make([]vt10x.Glyph, width)
+	for i := range line {
+		line[i] = vt10x.Glyph{Char: ' ', FG: vt10x.DefaultFG, BG: vt10x.DefaultBG}
+	}
+	for i, r := range []rune(text) {
+		line[i].Char = r
+	}
+	return line
+}
+
+func TestBufferDropsOldestLines(t *testing.T) {
+	b := NewBuffer(3, 0)
+	for _, text := range []string{"one", "two", "three", "four"} {
+		b.Push(glyphs(text, 10))
+	}
+
+	assert.Equal(t, 3, b.Len())
+	assert.Equal(t, "two", b.Lines()[0].String())
+	assert.Equal(t, "three\nfour\n", b.Text(2))
+	assert.Equal(t, "two\nthree\nfour\n", b.Text(10))
+}
+
+func TestBufferMemoryCap(t *testing.T) {
+	b := NewBuffer(100, 2*(lineOverhead+4*glyphSize))
+	for _, text := range []string{"aaaa", "bbbb", "cccc"} {
+		b.Push(glyphs(text, 80))
+	}
+
+	assert.Equal(t, 2, b.Len())
+	assert.Equal(t, "bbbb\ncccc\n", b.Text(2))
+}
+
+func TestBufferKeepsAttributes(t *testing.T) {
+	b := NewBuffer(10, 0)
+	line := glyphs("ok", 4)
+	line[0].Mode = vt10x.AttrBold
+	line[3].BG = vt10x.Red
+	b.Push(line)
+
+	lines := b.Lines()
+	assert.Equal(t, 4, len(lines[0]), "colored trailing blank kept")
+	assert.Equal(t, int16(vt10x.AttrBold), lines[0][0].Mode)
+}
+
+func TestDisabledBuffer(t *testing.T) {
+	b := NewBuffer(0, 0)
+	b.Push(glyphs("lost", 4))
+
+	assert.Equal(t, 0, b.Len())
+	assert.Equal(t, "", b.Text(10))
+}
@@ -0,0 +1,52 @@
+package scrollback
+
+import (
+	"fmt"
+	"os"
+)
+
+// Config sets the size of the scrollback buffer.
+type Config struct {
+	// MaxLines is the number of lines kept. Zero disables the scrollback buffer.
+	MaxLines int
+	// MaxBytes caps the memory used by the lines kept. Zero means no cap other than MaxLines.
+	MaxBytes int
+	// PromptLines is the number of the most recent lines offered to the prompt builder, in addition to
+	// the screen content.
+	PromptLines int
+}
+
+// DefaultConfig returns the configuration used when none is stored.
+func DefaultConfig() Config {
+	return Config{
+		MaxLines:    10000,
+		MaxBytes:    32 << 20,
+		PromptLines: 1000,
+	}
+}
+
+// configName is the name the configuration is stored under in the configuration repository.
+const configName = "scrollback"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+}
+
+// LoadConfig loads the configuration from the configuration repository. Settings missing from the
+// stored configuration keep their default value.
+func LoadConfig(configRepository configRepository) (Config, error) {
+	config := DefaultConfig()
+	err := configRepository.Load(configName, &config)
+	if err != nil && !os.IsNotExist(err) {
+		return config, fmt.Errorf("failed to load scrollback configuration: %w", err)
+	}
+	if config.MaxLines < 0 || config.MaxBytes < 0 || config.PromptLines < 0 {
+		return DefaultConfig(), fmt.Errorf("invalid scrollback configuration: sizes must not be negative")
+	}
+	return config, nil
+}
+
+// NewBuffer creates a buffer sized according to the configuration.
+func (c Config) NewBuffer() *Buffer {
+	return NewBuffer(c.MaxLines, c.MaxBytes)
+}
@@ -0,0 +1,21 @@
+package scrollback
+
+import (
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/config"
+)
+
+func TestLoadConfigRejectsNegativeSizes(t *testing.T) {
+	repo := config.NewRepository(t.TempDir())
+	assert.Nil(t, repo.Store(configName, map[string]int{"MaxLines": 5}))
+	c, err := LoadConfig(repo)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, c.MaxLines)
+	assert.Equal(t, DefaultConfig().PromptLines, c.PromptLines)
+
+	assert.Nil(t, repo.Store(configName, map[string]int{"MaxLines": -1}))
+	_, err = LoadConfig(repo)
+	assert.NotNil(t, err)
+}
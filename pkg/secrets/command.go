@@ -0,0 +1,101 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// service is the name secrets are stored under in password managers.
+const service = "witty"
+
+// run runs a command with the given input and returns its output.
+func run(input []byte, command string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(command, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return output, fmt.Errorf("%s failed: %w: %s", command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return output, nil
+}
+
+// PassBackend stores secrets in the pass password store, under witty/<name>.
+type PassBackend struct{}
+
+func (PassBackend) entry(name string) string {
+	return service + "/" + name
+}
+
+// Get returns the secret with the given name.
+func (b PassBackend) Get(name string) ([]byte, error) {
+	output, err := run(nil, "pass", "show", b.entry(name))
+	if err != nil {
+		if strings.Contains(err.Error(), "is not in the password store") {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return bytes.TrimRight(output, "\n"), nil
+}
+
+// Set stores the secret with the given name.
+func (b PassBackend) Set(name string, value []byte) error {
+	_, err := run(value, "pass", "insert", "--multiline", "--force", b.entry(name))
+	return err
+}
+
+// Delete deletes the secret with the given name.
+func (b PassBackend) Delete(name string) error {
+	_, err := run(nil, "pass", "rm", "--force", b.entry(name))
+	if err != nil && strings.Contains(err.Error(), "is not in the password store") {
+		return nil
+	}
+	return err
+}
+
+// KeyringBackend stores secrets in the Secret Service (GNOME Keyring, KWallet), through secret-tool. It is not
+// available on macOS: the security command only reads secrets from its arguments, which other processes can
+// see, or from the terminal, which the shell reads.
+type KeyringBackend struct{}
+
+// NewKeyringBackend returns the keyring backend, or an error where it is not available.
+func NewKeyringBackend() (KeyringBackend, error) {
+	if runtime.GOOS == "darwin" {
+		return KeyringBackend{}, errors.New("the keyring secrets backend is not available on macOS, use file or pass")
+	}
+	return KeyringBackend{}, nil
+}
+
+// Get returns the secret with the given name.
+func (KeyringBackend) Get(name string) ([]byte, error) {
+	output, err := run(nil, "secret-tool", "lookup", "service", service, "name", name)
+	// secret-tool exits with status 1 and no output when the secret is missing.
+	if err != nil && len(output) == 0 {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, ErrNotFound
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(output, "\n"), nil
+}
+
+// Set stores the secret with the given name. secret-tool reads it on stdin.
+func (KeyringBackend) Set(name string, value []byte) error {
+	_, err := run(value, "secret-tool", "store", "--label", service+" "+name, "service", service, "name", name)
+	return err
+}
+
+// Delete deletes the secret with the given name.
+func (KeyringBackend) Delete(name string) error {
+	_, err := run(nil, "secret-tool", "clear", "service", service, "name", name)
+	return err
+}
@@ -0,0 +1,251 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jjviana/codex/pkg/config"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keySize  = 32
+	saltSize = 16
+	// scrypt parameters recommended for interactive use.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// FileBackend encrypts secrets with AES-256-GCM in files of a directory, one per secret. The key is derived
+// with scrypt from a key file or a passphrase and a random salt stored in the directory.
+type FileBackend struct {
+	dir string
+	// secret returns the key file content or the passphrase. It is only called when a secret is first
+	// accessed, so that no passphrase is asked for when no secret is needed.
+	secret func() ([]byte, error)
+
+	once sync.Once
+	aead cipher.AEAD
+	err  error
+}
+
+// NewFileBackend creates a backend storing secrets in dir, encrypted with a key derived from the value
+// returned by secret.
+func NewFileBackend(dir string, secret func() ([]byte, error)) *FileBackend {
+	return &FileBackend{dir: dir, secret: secret}
+}
+
+// KeyFile returns a function reading the key file at path, creating it with a random key when missing.
+func KeyFile(path string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		key, err := ioutil.ReadFile(path)
+		if err == nil {
+			return key, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		key = make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		return createFile(path, key)
+	}
+}
+
+// createFile creates the file at path with the given content and returns it. If another process created the
+// file first, its content is returned instead. The file is linked into place once written, so that it is
+// never seen partially written.
+func createFile(path string, data []byte) ([]byte, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	err = os.Link(f.Name(), path)
+	if os.IsExist(err) {
+		return ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *FileBackend) cipher() (cipher.AEAD, error) {
+	b.once.Do(func() {
+		b.aead, b.err = b.newCipher()
+	})
+	return b.aead, b.err
+}
+
+func (b *FileBackend) newCipher() (cipher.AEAD, error) {
+	if err := os.MkdirAll(b.dir, 0o700); err != nil {
+		return nil, err
+	}
+	secret, err := b.secret()
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("empty secrets passphrase or key file")
+	}
+	salt, err := b.salt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(secret, salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// salt returns the salt of the directory, creating it on first use.
+func (b *FileBackend) salt() ([]byte, error) {
+	path := filepath.Join(b.dir, "salt")
+	salt, err := ioutil.ReadFile(path)
+	if err == nil {
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	salt = make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	// Processes starting at the same time must agree on the salt, or the secrets one of them stores could
+	// never be decrypted again.
+	return createFile(path, salt)
+}
+
+func (b *FileBackend) path(name string) string {
+	return filepath.Join(b.dir, name+".secret")
+}
+
+// Get decrypts the secret with the given name.
+func (b *FileBackend) Get(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(b.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := b.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("secret %s is corrupted", name)
+	}
+	// The name is authenticated with the value, so that secret files cannot be swapped.
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %s: wrong passphrase or key file", name)
+	}
+	return value, nil
+}
+
+// Exists reports whether the secret with the given name is stored, without decrypting it.
+func (b *FileBackend) Exists(name string) (bool, error) {
+	_, err := os.Stat(b.path(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Set encrypts the secret with the given name.
+func (b *FileBackend) Set(name string, value []byte) error {
+	unlock, err := b.lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return b.write(name, value)
+}
+
+// Update replaces the secret with the given name with the value returned by modify, which is passed the
+// current value or nil. Updates of the same secret, in this process or others, are serialized.
+func (b *FileBackend) Update(name string, modify func(value []byte) ([]byte, error)) error {
+	unlock, err := b.lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	value, err := b.Get(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	value, err = modify(value)
+	if err != nil {
+		return err
+	}
+	return b.write(name, value)
+}
+
+// Delete deletes the secret with the given name.
+func (b *FileBackend) Delete(name string) error {
+	unlock, err := b.lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	err = os.Remove(b.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// lock takes the lock of the secret with the given name, on a file of its own as the secret file is replaced on
+// every write.
+func (b *FileBackend) lock(name string) (func(), error) {
+	if err := os.MkdirAll(b.dir, 0o700); err != nil {
+		return nil, err
+	}
+	return config.Lock(filepath.Join(b.dir, "."+name+".lock"))
+}
+
+// write encrypts the value and replaces the secret file atomically. It is called with the lock of the secret
+// held.
+func (b *FileBackend) write(name string, value []byte) error {
+	aead, err := b.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return config.WriteFile(b.path(name), aead.Seal(nonce, nonce, value, []byte(name)))
+}
@@ -0,0 +1,284 @@
+// Package secrets stores credentials apart from the plain configuration: encrypted at rest in the
+// configuration directory, or in an external password manager. Credentials can also be read from
+// environment variables or commands, so that they never touch the disk.
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+)
+
+// ErrNotFound is returned when a secret is not stored. It matches fs.ErrNotExist under errors.Is, like the
+// errors of missing configurations, so that callers need not tell secrets from configurations.
+var ErrNotFound error = notFoundError{}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string {
+	return "secret not found"
+}
+
+func (notFoundError) Is(target error) bool {
+	return target == fs.ErrNotExist
+}
+
+// Backend stores secret values by name.
+type Backend interface {
+	// Get returns the value of the secret, or ErrNotFound.
+	Get(name string) ([]byte, error)
+	Set(name string, value []byte) error
+	// Delete removes the secret. Deleting a secret that is not stored is not an error.
+	Delete(name string) error
+}
+
+// Source reads a secret from outside the backend. Sources are tried before the backend.
+type Source struct {
+	// Env is the environment variable holding the secret.
+	Env string
+	// Command is a shell command printing the secret, such as "op read op://Private/OpenAI/credential".
+	Command string
+}
+
+// Config selects where secrets are stored.
+type Config struct {
+	// Backend is "file" to encrypt secrets in the configuration directory, "pass" to store them in the pass
+	// password store or "keyring" for the Secret Service.
+	Backend string
+	// Passphrase derives the encryption key of the file backend from a passphrase, read from the
+	// WITTY_PASSPHRASE environment variable or asked for, instead of a key file.
+	Passphrase bool
+	// KeyFile is the key file of the file backend. It is created with a random key when missing.
+	KeyFile string
+	// Sources maps secret names to their sources.
+	Sources map[string]Source
+}
+
+// DefaultConfig returns the configuration used when none is stored.
+func DefaultConfig() Config {
+	return Config{
+		Backend: "file",
+		Sources: map[string]Source{
+			"openai-api-key": {Env: "OPENAI_API_KEY"},
+		},
+	}
+}
+
+// configName is the name the configuration is stored under in the configuration repository.
+const configName = "secrets"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+}
+
+// LoadConfig loads the configuration from the configuration repository. Settings missing from the
+// stored configuration keep their default value.
+func LoadConfig(configRepository configRepository) (Config, error) {
+	config := DefaultConfig()
+	err := configRepository.Load(configName, &config)
+	if err != nil && !os.IsNotExist(err) {
+		return config, fmt.Errorf("failed to load secrets configuration: %w", err)
+	}
+	return config, nil
+}
+
+// Store stores JSON-serializable secrets in a backend, reading them from their sources first.
+type Store struct {
+	backend Backend
+	sources map[string]Source
+}
+
+// NewStore creates a store of secrets kept in the given backend.
+func NewStore(backend Backend, sources map[string]Source) *Store {
+	return &Store{backend: backend, sources: sources}
+}
+
+// Load loads the secret with the given name into v. The value of a source is a JSON string.
+func (s *Store) Load(name string, v interface{}) error {
+	value, ok, err := s.fromSource(name)
+	if err != nil {
+		return err
+	}
+	if ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, v)
+	}
+
+	data, err := s.backend.Get(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *Store) fromSource(name string) (string, bool, error) {
+	source, ok := s.sources[name]
+	if !ok {
+		return "", false, nil
+	}
+	if source.Env != "" {
+		if value := os.Getenv(source.Env); value != "" {
+			return value, true, nil
+		}
+	}
+	if source.Command != "" {
+		var stderr bytes.Buffer
+		cmd := exec.Command("/bin/sh", "-c", source.Command)
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read secret %s with %q: %w: %s", name, source.Command, err,
+				bytes.TrimSpace(stderr.Bytes()))
+		}
+		return string(bytes.TrimRight(output, "\r\n")), true, nil
+	}
+	return "", false, nil
+}
+
+// Store stores v as the secret with the given name.
+func (s *Store) Store(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.backend.Set(name, data)
+}
+
+// updater is implemented by backends that can update a secret atomically.
+type updater interface {
+	Update(name string, modify func(value []byte) ([]byte, error)) error
+}
+
+// exister is implemented by backends that can tell whether a secret is stored without reading it.
+type exister interface {
+	Exists(name string) (bool, error)
+}
+
+// Exists reports whether the secret with the given name is stored in the backend. Sources are not read.
+func (s *Store) Exists(name string) (bool, error) {
+	if e, ok := s.backend.(exister); ok {
+		return e.Exists(name)
+	}
+	_, err := s.backend.Get(name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Update loads the secret with the given name into v, lets modify change it and stores it. v keeps its content
+// if the secret is not stored yet. Sources are not read: the secret is updated in the backend, under its lock
+// when the backend has one. Nothing is stored if modify returns an error.
+func (s *Store) Update(name string, v interface{}, modify func() error) error {
+	update := func(value []byte) ([]byte, error) {
+		if value != nil {
+			if err := json.Unmarshal(value, v); err != nil {
+				return nil, err
+			}
+		}
+		if err := modify(); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+	if u, ok := s.backend.(updater); ok {
+		return u.Update(name, update)
+	}
+	value, err := s.backend.Get(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if value, err = update(value); err != nil {
+		return err
+	}
+	return s.backend.Set(name, value)
+}
+
+// Delete deletes the secret with the given name.
+func (s *Store) Delete(name string) error {
+	return s.backend.Delete(name)
+}
+
+type repository interface {
+	Store(name string, config interface{}) error
+	Load(name string, config interface{}) error
+	Update(name string, config interface{}, modify func() error) error
+	Delete(name string) error
+	Exists(name string) (bool, error)
+}
+
+// Repository is a configuration repository keeping the configurations with the given secret names in a
+// secret store, and the others in a plain configuration repository. Secrets stored in plain configuration
+// by earlier versions are moved to the secret store when loaded.
+type Repository struct {
+	repository
+	secrets *Store
+	names   map[string]bool
+}
+
+// NewRepository creates a configuration repository keeping the named configurations in the secret store.
+func NewRepository(config repository, secrets *Store, names ...string) *Repository {
+	r := &Repository{repository: config, secrets: secrets, names: map[string]bool{}}
+	for _, name := range names {
+		r.names[name] = true
+	}
+	return r
+}
+
+// Load loads the configuration with the given name.
+func (r *Repository) Load(name string, config interface{}) error {
+	if !r.names[name] {
+		return r.repository.Load(name, config)
+	}
+	err := r.secrets.Load(name, config)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if plainErr := r.repository.Load(name, config); plainErr != nil {
+		return err
+	}
+	if err := r.secrets.Store(name, config); err != nil {
+		return fmt.Errorf("failed to move %s to the secret store: %w", name, err)
+	}
+	return r.repository.Delete(name)
+}
+
+// Store stores the configuration with the given name.
+func (r *Repository) Store(name string, config interface{}) error {
+	if !r.names[name] {
+		return r.repository.Store(name, config)
+	}
+	return r.secrets.Store(name, config)
+}
+
+// Update loads the configuration with the given name, lets modify change it and stores it, holding its lock
+// throughout so that concurrent updates are not lost.
+func (r *Repository) Update(name string, config interface{}, modify func() error) error {
+	if !r.names[name] {
+		return r.repository.Update(name, config, modify)
+	}
+	return r.secrets.Update(name, config, modify)
+}
+
+// Exists reports whether the configuration with the given name is stored, in the secret store for secret names.
+func (r *Repository) Exists(name string) (bool, error) {
+	if !r.names[name] {
+		return r.repository.Exists(name)
+	}
+	return r.secrets.Exists(name)
+}
+
+// Delete deletes the configuration with the given name.
+func (r *Repository) Delete(name string) error {
+	if !r.names[name] {
+		return r.repository.Delete(name)
+	}
+	return r.secrets.Delete(name)
+}
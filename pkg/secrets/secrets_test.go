@@ -0,0 +1,141 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/jjviana/codex/pkg/config"
+)
+
+type token struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+func passphrase(p string) func() ([]byte, error) {
+	return func() ([]byte, error) { return []byte(p), nil }
+}
+
+func TestFileBackend(t *testing.T) {
+	dir := t.TempDir()
+	b := NewFileBackend(filepath.Join(dir, "secrets"), KeyFile(filepath.Join(dir, "secrets.key")))
+	_, err := b.Get("token")
+	assert.Equal(t, ErrNotFound, err)
+	assert.True(t, errors.Is(err, fs.ErrNotExist), "missing secrets are missing files for callers")
+
+	assert.Nil(t, b.Set("token", []byte("s3cr3t")))
+	value, err := b.Get("token")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cr3t", string(value))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "secrets", "token.secret"))
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(data, []byte("s3cr3t")), "secrets are encrypted at rest")
+	info, err := os.Stat(filepath.Join(dir, "secrets.key"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Another key file cannot decrypt the secret, nor can a secret file be renamed.
+	other := NewFileBackend(filepath.Join(dir, "secrets"), passphrase("guess"))
+	exists, err := NewStore(other, nil).Exists("token")
+	assert.Nil(t, err)
+	assert.True(t, exists, "existence is checked without decrypting")
+	_, err = other.Get("token")
+	assert.NotNil(t, err)
+	assert.Nil(t, os.Rename(filepath.Join(dir, "secrets", "token.secret"), filepath.Join(dir, "secrets", "other.secret")))
+	_, err = b.Get("other")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, b.Delete("other"))
+	assert.Nil(t, b.Delete("other"))
+}
+
+func TestFileBackendUpdate(t *testing.T) {
+	dir := t.TempDir()
+	key := KeyFile(filepath.Join(dir, "secrets.key"))
+	// Updates of separate backends, as in separate processes, are not lost.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		store := NewStore(NewFileBackend(filepath.Join(dir, "secrets"), key), nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				var count int
+				assert.Nil(t, store.Update("count", &count, func() error {
+					count++
+					return nil
+				}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	assert.Nil(t, NewStore(NewFileBackend(filepath.Join(dir, "secrets"), key), nil).Load("count", &count))
+	assert.Equal(t, 40, count)
+}
+
+func TestCreateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "salt")
+	data, err := createFile(path, []byte("first"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(data))
+	// A process losing the race uses the content of the winner.
+	data, err = createFile(path, []byte("second"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(data))
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files), "temporary files are removed")
+}
+
+func TestStoreSources(t *testing.T) {
+	b := NewFileBackend(t.TempDir(), passphrase("passphrase"))
+	s := NewStore(b, map[string]Source{
+		"env":     {Env: "WITTY_TEST_SECRET"},
+		"command": {Command: "echo from-command"},
+	})
+	assert.Nil(t, s.Store("env", "stored"))
+
+	var value string
+	assert.Nil(t, s.Load("env", &value))
+	assert.Equal(t, "stored", value)
+	os.Setenv("WITTY_TEST_SECRET", "from-env")
+	defer os.Unsetenv("WITTY_TEST_SECRET")
+	assert.Nil(t, s.Load("env", &value))
+	assert.Equal(t, "from-env", value)
+	assert.Nil(t, s.Load("command", &value))
+	assert.Equal(t, "from-command", value)
+}
+
+func TestRepositoryMovesPlainSecrets(t *testing.T) {
+	dir := t.TempDir()
+	plain := config.NewRepository(dir)
+	stored := token{AccessToken: "access", RefreshToken: "refresh"}
+	assert.Nil(t, plain.Store("token", stored))
+	assert.Nil(t, plain.Store("settings", map[string]int{"n": 1}))
+
+	store := NewStore(NewFileBackend(filepath.Join(dir, "secrets"), passphrase("passphrase")), nil)
+	r := NewRepository(plain, store, "token")
+	var loaded token
+	assert.Nil(t, r.Load("token", &loaded))
+	assert.Equal(t, stored, loaded)
+	_, err := os.Stat(filepath.Join(dir, "token.json"))
+	assert.True(t, os.IsNotExist(err), "the plaintext secret is removed")
+
+	loaded = token{}
+	assert.Nil(t, r.Load("token", &loaded))
+	assert.Equal(t, stored, loaded)
+	var settings map[string]int
+	assert.Nil(t, r.Load("settings", &settings))
+	assert.Equal(t, 1, settings["n"])
+
+	assert.NotNil(t, r.Load("missing", &loaded))
+}
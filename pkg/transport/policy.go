@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Policy configures timeouts, retries and concurrency of the HTTP calls engines make.
+type Policy struct {
+	// ConnectTimeout bounds establishing a connection, including the TLS handshake.
+	ConnectTimeout Duration
+	// Timeout bounds a single attempt, from sending the request to reading the whole response.
+	Timeout Duration
+	// MaxRetries is how many times a failed request is retried. Zero disables retries.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. It doubles on every retry, with jitter.
+	InitialBackoff Duration
+	// MaxBackoff caps the delay between retries. A Retry-After longer than this is not waited for:
+	// the response is returned to the engine instead.
+	MaxBackoff Duration
+	// MaxConcurrency limits the requests in flight at any time. Zero means no limit.
+	MaxConcurrency int
+}
+
+// DefaultPolicy returns the policy used when none is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		ConnectTimeout: Duration(5 * time.Second),
+		Timeout:        Duration(20 * time.Second),
+		MaxRetries:     2,
+		InitialBackoff: Duration(250 * time.Millisecond),
+		MaxBackoff:     Duration(5 * time.Second),
+		MaxConcurrency: 4,
+	}
+}
+
+// policyName is the name the policy is stored under in the configuration repository.
+const policyName = "transport"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+}
+
+// LoadPolicy loads the policy from the configuration repository. Settings missing from the stored
+// policy keep their default value, and the default policy is returned when none is stored.
+func LoadPolicy(configRepository configRepository) (Policy, error) {
+	policy := DefaultPolicy()
+	err := configRepository.Load(policyName, &policy)
+	if err != nil && !os.IsNotExist(err) {
+		return policy, fmt.Errorf("failed to load transport policy: %w", err)
+	}
+	return policy, nil
+}
+
+// Duration is a time.Duration that is stored in JSON as a string such as "5s" or "250ms".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are strings such as \"5s\": %w", err)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
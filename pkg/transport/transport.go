@@ -0,0 +1,225 @@
+// Package transport provides the HTTP client engines use to talk to their services. It bounds connection
+// and request times, retries failed requests with jittered exponential backoff, honors Retry-After,
+// limits the requests in flight and goes through the proxy configured in HTTP_PROXY and HTTPS_PROXY.
+package transport
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// NewClient returns an HTTP client applying the given policy.
+func NewClient(policy Policy) *http.Client {
+	return &http.Client{Transport: NewTransport(policy)}
+}
+
+// NewTransport returns a round tripper applying the given policy on top of a new connection pool.
+func NewTransport(policy Policy) *RoundTripper {
+	return NewRoundTripper(policy, newBaseTransport(policy))
+}
+
+func newBaseTransport(policy Policy) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(policy.ConnectTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   time.Duration(policy.ConnectTimeout),
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// RoundTripper is an http.RoundTripper applying a Policy on top of another round tripper.
+type RoundTripper struct {
+	policy Policy
+	next   http.RoundTripper
+	slots  chan struct{}
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+// NewRoundTripper returns a round tripper applying the policy to the requests it sends through next.
+func NewRoundTripper(policy Policy, next http.RoundTripper) *RoundTripper {
+	t := &RoundTripper{
+		policy: policy,
+		next:   next,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if policy.MaxConcurrency > 0 {
+		t.slots = make(chan struct{}, policy.MaxConcurrency)
+	}
+	return t
+}
+
+// RoundTrip sends the request, retrying it according to the policy. Only requests whose body can be
+// replayed are retried. The engines' requests have no side effects, so every method is retried.
+func (t *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := t.acquire(ctx); err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		attemptReq, cancel, err := t.prepare(req, attempt)
+		if err != nil {
+			t.release()
+			return nil, err
+		}
+		resp, err := t.next.RoundTrip(attemptReq)
+		delay, retry := t.retryDelay(req, resp, err, attempt)
+		if !retry {
+			if err != nil {
+				cancel()
+				t.release()
+				return nil, err
+			}
+			// The attempt context and the concurrency slot are released once the body is consumed.
+			resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() {
+				cancel()
+				t.release()
+			}}
+			return resp, nil
+		}
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+			_ = resp.Body.Close()
+		}
+		cancel()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			t.release()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// prepare returns the request to send for the given attempt, bounded by the attempt timeout.
+func (t *RoundTripper) prepare(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.policy.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(t.policy.Timeout))
+	}
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// retryDelay decides whether the outcome of an attempt calls for a retry, and how long to wait before it.
+func (t *RoundTripper) retryDelay(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= t.policy.MaxRetries || req.Context().Err() != nil {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The body was consumed by the first attempt and cannot be sent again.
+		return 0, false
+	}
+	delay := t.backoff(attempt)
+	if err != nil {
+		return delay, true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if retryAfter := ParseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 {
+			if retryAfter > time.Duration(t.policy.MaxBackoff) {
+				// Not worth waiting for: let the caller back off.
+				return 0, false
+			}
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+		return delay, true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return delay, true
+	}
+	return 0, false
+}
+
+// backoff returns the delay before the retry following the given attempt: exponential, capped and jittered
+// between half and the whole of the exponential delay.
+func (t *RoundTripper) backoff(attempt int) time.Duration {
+	delay := time.Duration(t.policy.InitialBackoff)
+	for i := 0; i < attempt && delay < time.Duration(t.policy.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if delay > time.Duration(t.policy.MaxBackoff) {
+		delay = time.Duration(t.policy.MaxBackoff)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	jitter := time.Duration(t.rand.Int63n(int64(delay)/2 + 1))
+	t.mu.Unlock()
+	return delay/2 + jitter
+}
+
+func (t *RoundTripper) acquire(ctx context.Context) error {
+	if t.slots == nil {
+		return nil
+	}
+	select {
+	case t.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *RoundTripper) release() {
+	if t.slots != nil {
+		<-t.slots
+	}
+}
+
+// releasingBody runs release once, when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, either a number of seconds or an HTTP date.
+// It returns zero when the value is missing or invalid.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
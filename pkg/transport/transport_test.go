@@ -0,0 +1,147 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/autarch/testify/assert"
+)
+
+func testPolicy() Policy {
+	policy := DefaultPolicy()
+	policy.InitialBackoff = Duration(time.Millisecond)
+	policy.MaxBackoff = Duration(2 * time.Second)
+	return policy
+}
+
+// statusServer answers with the given statuses in order, then with 200.
+func statusServer(statuses ...int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n <= len(statuses) {
+			if statuses[n-1] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	return server, &calls
+}
+
+func post(t *testing.T, client *http.Client, url string) *http.Response {
+	req, err := http.NewRequest("POST", url, strings.NewReader(`{"prompt": "ls"}`))
+	assert.NoError(t, err)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestRetries(t *testing.T) {
+	server, calls := statusServer(http.StatusBadGateway, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	resp := post(t, NewClient(testPolicy()), server.URL)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRetriesAreLimited(t *testing.T) {
+	server, calls := statusServer(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer server.Close()
+
+	resp := post(t, NewClient(testPolicy()), server.URL)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+}
+
+func TestRetryAfter(t *testing.T) {
+	server, calls := statusServer(http.StatusTooManyRequests)
+	defer server.Close()
+
+	start := time.Now()
+	resp := post(t, NewClient(testPolicy()), server.URL)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, time.Since(start) >= time.Second)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// A Retry-After longer than the maximum backoff is left to the caller
+	server, calls = statusServer(http.StatusTooManyRequests)
+	defer server.Close()
+	policy := testPolicy()
+	policy.MaxBackoff = Duration(100 * time.Millisecond)
+	resp = post(t, NewClient(policy), server.URL)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	policy := testPolicy()
+	policy.Timeout = Duration(50 * time.Millisecond)
+	policy.MaxRetries = 1
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("{}"))
+	start := time.Now()
+	_, err := NewClient(policy).Do(req)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	policy := testPolicy()
+	policy.MaxConcurrency = 2
+	client := NewClient(policy)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := post(t, client, server.URL)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	assert.True(t, atomic.LoadInt32(&maxInFlight) <= 2)
+}
+
+func TestPolicyJSON(t *testing.T) {
+	var policy Policy
+	err := policy.ConnectTimeout.UnmarshalJSON([]byte(`"1.5s"`))
+	assert.NoError(t, err)
+	assert.Equal(t, Duration(1500*time.Millisecond), policy.ConnectTimeout)
+	assert.Error(t, policy.Timeout.UnmarshalJSON([]byte(`5`)))
+}
@@ -0,0 +1,70 @@
+package witty
+
+import (
+	"strings"
+
+	"github.com/jjviana/codex/pkg/analytics"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// markers are the labels of the markers recorded for each type of suggestion event.
+var markers = map[string]string{
+	analytics.Shown:             MarkerSuggestionShown,
+	analytics.Accepted:          MarkerSuggestionAccepted,
+	analytics.PartiallyAccepted: MarkerSuggestionPartiallyAccepted,
+	analytics.Dismissed:         MarkerSuggestionDismissed,
+}
+
+// SetAnalytics logs the suggestion events to the given event log, attributing them to the named engine.
+func (w *Witty) SetAnalytics(l *analytics.Log, engineName string) {
+	w.analytics = l
+	w.engineMu.Lock()
+	w.engineName = engineName
+	w.engineMu.Unlock()
+}
+
+// suggestionEvent records a suggestion event, of which accepted bytes were accepted, in the session recording
+// and the event log. Empty suggestions are not shown, so they are not recorded.
+func (w *Witty) suggestionEvent(eventType string, suggestion engine.Suggestion, accepted int) {
+	if suggestion == nil || suggestion.Text() == "" {
+		return
+	}
+	text := suggestion.Text()
+	if w.recorder != nil {
+		label := text
+		if eventType == analytics.PartiallyAccepted {
+			label = text[:accepted]
+		}
+		if eventType == analytics.Shown {
+			w.recorder.Marker(ShownMarker(w.suggestionProgram, label))
+		} else {
+			w.recorder.Marker(markers[eventType] + label)
+		}
+	}
+	if w.analytics != nil {
+		event := analytics.Event{
+			Type:    eventType,
+			Engine:  w.suggestionEngineName,
+			Program: w.suggestionProgram,
+			Latency: w.suggestionLatency,
+		}
+		if err := w.analytics.Record(event, text, accepted); err != nil {
+			log.Error().Err(err).Msg("failed to log suggestion event")
+		}
+	}
+}
+
+// nextWord returns the length of the next word of the suggestion, including the blanks before it.
+func nextWord(text string) int {
+	start := len(text) - len(strings.TrimLeft(text, " \t"))
+	end := strings.IndexAny(text[start:], " \t\n")
+	if end < 0 {
+		return len(text)
+	}
+	if end == 0 {
+		// The suggestion continues on the next line.
+		return start + 1
+	}
+	return start + end
+}
@@ -1,4 +1,5 @@
-//+build darwin freebsd
+//go:build darwin || freebsd
+// +build darwin freebsd
 
 package witty
 
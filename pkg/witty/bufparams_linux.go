@@ -1,4 +1,6 @@
-//+build linux
+//go:build linux
+// +build linux
+
 package witty
 
 import (
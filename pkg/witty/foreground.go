@@ -0,0 +1,27 @@
+package witty
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// foregroundProcessGroup returns the id of the process group in the foreground of the terminal
+// connected to the given pty master.
+func foregroundProcessGroup(pty *os.File) (int, error) {
+	// We go through the raw connection instead of pty.Fd() as the latter would switch
+	// the file to blocking mode.
+	conn, err := pty.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var pgrp int
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		pgrp, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pgrp, ioctlErr
+}
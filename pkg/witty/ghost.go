@@ -0,0 +1,45 @@
+package witty
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// tabWidth is the distance between tab stops in ghost text.
+const tabWidth = 8
+
+// drawGhostText draws text from column x of row y. Each grapheme cluster is drawn in one cell, or two for
+// wide characters. Lines wrap at the screen width and text below the bottom of the screen is clipped.
+// It returns the position following the text.
+func drawGhostText(s tcell.Screen, text string, x, y, width, height int, style tcell.Style) (int, int) {
+	graphemes := uniseg.NewGraphemes(text)
+	for graphemes.Next() && y < height {
+		runes := graphemes.Runes()
+		switch runes[0] {
+		case '\n':
+			x, y = 0, y+1
+			continue
+		case '\t':
+			next := (x/tabWidth + 1) * tabWidth
+			for ; x < next && x < width; x++ {
+				s.SetContent(x, y, ' ', nil, style)
+			}
+			continue
+		}
+		w := runewidth.StringWidth(graphemes.Str())
+		if w == 0 {
+			// Control characters and stray combining marks take no cell.
+			continue
+		}
+		if x+w > width {
+			x, y = 0, y+1
+			if y >= height {
+				break
+			}
+		}
+		s.SetContent(x, y, runes[0], runes[1:], style)
+		x += w
+	}
+	return x, y
+}
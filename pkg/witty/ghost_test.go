@@ -0,0 +1,52 @@
+package witty
+
+import (
+	"testing"
+
+	"github.com/autarch/testify/assert"
+	"github.com/gdamore/tcell/v2"
+)
+
+func newTestScreen(t *testing.T, width, height int) tcell.SimulationScreen {
+	s := tcell.NewSimulationScreen("UTF-8")
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSize(width, height)
+	return s
+}
+
+// screenRow returns the runes of each cell of a simulated screen row, with wide characters followed by their
+// continuation cell.
+func screenRow(s tcell.SimulationScreen, y int) []string {
+	cells, width, _ := s.GetContents()
+	var row []string
+	for _, cell := range cells[y*width : (y+1)*width] {
+		row = append(row, string(cell.Runes))
+	}
+	return row
+}
+
+func TestDrawGhostTextWideCharacters(t *testing.T) {
+	s := newTestScreen(t, 6, 2)
+	defer s.Fini()
+
+	x, y := drawGhostText(s, "ñé世界!", 1, 0, 6, 2, tcell.StyleDefault)
+	s.Show()
+
+	assert.Equal(t, []string{" ", "ñ", "é", "世", "", " "}, screenRow(s, 0), "combining mark kept with its base")
+	assert.Equal(t, []string{"界", "", "!", " ", " ", " "}, screenRow(s, 1), "wide character wrapped")
+	assert.Equal(t, 3, x)
+	assert.Equal(t, 1, y)
+}
+
+func TestDrawGhostTextClipsAtBottom(t *testing.T) {
+	s := newTestScreen(t, 4, 2)
+	defer s.Fini()
+
+	_, y := drawGhostText(s, "abc\ndef\nghi", 0, 0, 4, 2, tcell.StyleDefault)
+	s.Show()
+
+	assert.Equal(t, []string{"d", "e", "f", " "}, screenRow(s, 1))
+	assert.Equal(t, 2, y, "drawing stopped at the bottom")
+}
@@ -0,0 +1,64 @@
+package witty
+
+import (
+	"io/ioutil"
+
+	"github.com/ActiveState/vt10x"
+	"github.com/jjviana/codex/pkg/scrollback"
+)
+
+// Headless emulates the terminal without a screen or a shell, extracting prompts the same way witty does.
+// It is used to evaluate engines and prompts offline on recorded sessions.
+type Headless struct {
+	state                 vt10x.State
+	vterm                 *vt10x.VT
+	scrollback            *scrollback.Buffer
+	scrollbackPromptLines int
+}
+
+// NewHeadless creates a headless terminal of the given size.
+func NewHeadless(width, height int, scrollbackConfig scrollback.Config) (*Headless, error) {
+	h := &Headless{
+		scrollback:            scrollbackConfig.NewBuffer(),
+		scrollbackPromptLines: scrollbackConfig.PromptLines,
+	}
+	h.state.OnScrollOut = h.scrollback.Push
+	var err error
+	// Replies of the terminal to the program, such as cursor position reports, are discarded.
+	h.vterm, err = vt10x.New(&h.state, nil, ioutil.Discard)
+	if err != nil {
+		return nil, err
+	}
+	h.vterm.Resize(width, height)
+	return h, nil
+}
+
+// Write parses output of the shell.
+func (h *Headless) Write(data []byte) (int, error) {
+	return h.vterm.Write(data)
+}
+
+// Resize changes the terminal size.
+func (h *Headless) Resize(width, height int) {
+	h.vterm.Resize(width, height)
+}
+
+// Cursor returns the cursor position.
+func (h *Headless) Cursor() (int, int) {
+	h.state.Lock()
+	defer h.state.Unlock()
+	return h.state.Cursor()
+}
+
+// LineSuffix returns the text of screen row y from column x on, without trailing blanks.
+func (h *Headless) LineSuffix(x, y int) string {
+	h.state.Lock()
+	defer h.state.Unlock()
+	return lineSuffix(&h.state, x, y)
+}
+
+// Prompt returns the terminal content witty would build a prompt from, before it is fit into the engine
+// token budget.
+func (h *Headless) Prompt() string {
+	return promptText(&h.state, h.scrollback, h.scrollbackPromptLines)
+}
@@ -0,0 +1,126 @@
+package witty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Keymap binds the keys witty handles itself. Keys are named like "tab", "ctrl-o", "alt-f" or "ctrl-right".
+// A bound key only reaches the shell when its action does not apply, like the accept keys when no
+// suggestion is shown.
+type Keymap struct {
+	// Accept inserts the whole suggestion.
+	Accept []string
+	// AcceptWord inserts the next word of the suggestion.
+	AcceptWord []string
+	// Suggestions lists the suggestions to choose from, or logs in again once the engine credentials expired.
+	Suggestions []string
+	// Scrollback opens the scrollback view.
+	Scrollback []string
+}
+
+// DefaultKeymap returns the keymap used when none is configured. The next word is accepted with Alt-f, as
+// in fish, or Ctrl-Right.
+func DefaultKeymap() Keymap {
+	return Keymap{
+		Accept:      []string{"tab"},
+		AcceptWord:  []string{"alt-f", "ctrl-right"},
+		Suggestions: []string{"ctrl-o"},
+		Scrollback:  []string{"ctrl-]"},
+	}
+}
+
+// keymapName is the name the keymap is stored under in the configuration repository.
+const keymapName = "keymap"
+
+// LoadKeymap loads the keymap from the configuration repository. Actions missing from the stored keymap keep
+// their default keys.
+func LoadKeymap(configRepository configRepository) (Keymap, error) {
+	keymap := DefaultKeymap()
+	err := configRepository.Load(keymapName, &keymap)
+	if err != nil && !os.IsNotExist(err) {
+		return keymap, fmt.Errorf("failed to load keymap: %w", err)
+	}
+	for _, keys := range [][]string{keymap.Accept, keymap.AcceptWord, keymap.Suggestions, keymap.Scrollback} {
+		for _, key := range keys {
+			if _, err := keySequences(key); err != nil {
+				return keymap, fmt.Errorf("invalid keymap: %w", err)
+			}
+		}
+	}
+	return keymap, nil
+}
+
+// cursorKeys are the final bytes of the cursor key sequences.
+var cursorKeys = map[string]byte{"up": 'A', "down": 'B', "right": 'C', "left": 'D', "end": 'F', "home": 'H'}
+
+// keySequences returns the input sequences a key sends.
+func keySequences(key string) ([]string, error) {
+	name := strings.ToLower(key)
+	switch name {
+	case "tab":
+		return []string{"\t"}, nil
+	case "enter":
+		return []string{"\r"}, nil
+	case "esc":
+		return []string{"\x1b"}, nil
+	}
+	if c, ok := cursorKeys[name]; ok {
+		// Normal and application cursor keys mode.
+		return []string{"\x1b[" + string(c), "\x1bO" + string(c)}, nil
+	}
+	modifier, rest := "", name
+	if i := strings.Index(name, "-"); i > 0 && i < len(name)-1 {
+		modifier, rest = name[:i], name[i+1:]
+	}
+	if c, ok := cursorKeys[rest]; ok {
+		switch modifier {
+		case "alt":
+			return []string{"\x1b[1;3" + string(c)}, nil
+		case "ctrl":
+			return []string{"\x1b[1;5" + string(c)}, nil
+		}
+	}
+	if len(rest) == 1 {
+		switch c := rest[0]; {
+		case modifier == "alt" && c > ' ' && c < 0x7f:
+			return []string{"\x1b" + rest}, nil
+		case modifier == "ctrl" && c >= 'a' && c <= 'z':
+			return []string{string(c - 'a' + 1)}, nil
+		case modifier == "ctrl" && c >= '[' && c <= '_':
+			return []string{string(c - '@')}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", key)
+}
+
+// match returns the length of the sequence of one of the keys the input starts with, or 0 if it starts with
+// none of them.
+func match(keys []string, data []byte) int {
+	for _, key := range keys {
+		sequences, _ := keySequences(key)
+		for _, sequence := range sequences {
+			// Escape starts the sequences of other keys, so it only matches by itself.
+			if bytes.HasPrefix(data, []byte(sequence)) && (sequence != "\x1b" || len(data) == 1) {
+				return len(sequence)
+			}
+		}
+	}
+	return 0
+}
+
+// keyLabel returns the name of the first of the keys as shown to the user, such as Ctrl-O.
+func keyLabel(keys []string) string {
+	if len(keys) == 0 {
+		return "(unbound)"
+	}
+	parts := strings.Split(keys[0], "-")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "-")
+}
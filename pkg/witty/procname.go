@@ -0,0 +1,36 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package witty
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processName returns the command name of the process with the given pid.
+func processName(pid int) (string, error) {
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(strings.TrimSpace(string(out))), nil
+}
+
+// processDirectory returns the working directory of the process with the given pid.
+func processDirectory(pid int) (string, error) {
+	out, err := exec.Command("lsof", "-a", "-p", strconv.Itoa(pid), "-d", "cwd", "-Fn").Output()
+	if err != nil {
+		return "", err
+	}
+	// lsof prints one field per line, prefixed with its name: the name field holds the path.
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "n") {
+			return line[1:], nil
+		}
+	}
+	return "", fmt.Errorf("no working directory reported for process %d", pid)
+}
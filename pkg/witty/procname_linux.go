@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package witty
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processName returns the command name of the process with the given pid.
+func processName(pid int) (string, error) {
+	comm, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/comm")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(comm)), nil
+}
+
+// processDirectory returns the working directory of the process with the given pid.
+func processDirectory(pid int) (string, error) {
+	return os.Readlink("/proc/" + strconv.Itoa(pid) + "/cwd")
+}
@@ -0,0 +1,165 @@
+package witty
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/jjviana/codex/pkg/prompt"
+	"github.com/rs/zerolog/log"
+)
+
+// EngineFactory creates the engine with the given name, along with the builder of its prompts.
+type EngineFactory func(name string) (engine.SuggestionEngine, *prompt.Builder, error)
+
+// projectEngine is an engine created for a project. Its engine is nil if it could not be created.
+type projectEngine struct {
+	engine  engine.SuggestionEngine
+	builder *prompt.Builder
+}
+
+// SetProjects applies the .witty.toml files of the directory the shell is in and of its ancestors. engineName
+// is the name of the engine witty was started with, used where no project selects another one, and engines
+// creates the engines projects select.
+func (w *Witty) SetProjects(trust *project.Trust, engineName string, engines EngineFactory) {
+	suggestionEngine, builder, _ := w.currentEngine()
+	w.setEngine(suggestionEngine, builder, engineName)
+	w.projectTrust = trust
+	w.projectFiles = project.NewCache()
+	w.defaultEngine = engineName
+	w.engines = engines
+	w.projectEngines = map[string]projectEngine{engineName: {suggestionEngine, builder}}
+	w.trustMu.Lock()
+	w.notifiedProjects = map[string]string{}
+	w.trustMu.Unlock()
+}
+
+// updateProject reads the project configuration of the directory the shell is in, and tells the user about
+// the files with sensitive settings. It runs before every suggestion request, so that changes apply right
+// away: the files are only read again once modified.
+func (w *Witty) updateProject() {
+	if w.projectTrust == nil {
+		return
+	}
+	dir, err := w.shellDirectory()
+	if err != nil {
+		log.Debug().Msgf("failed to determine the directory of the shell: %v", err)
+		return
+	}
+	files, err := w.projectFiles.Lookup(dir)
+	if err != nil {
+		// The redaction rules of an invalid file cannot be honored: no prompt is sent until it is fixed.
+		w.project = project.Project{}
+		if message := fmt.Sprintf("witty: %s, suggestions disabled", err); message != w.projectError {
+			w.projectError = message
+			w.setStatusMessage(message)
+		}
+		return
+	}
+	w.projectError = ""
+	w.project = project.Resolve(files, w.projectTrust.Trusted)
+	if len(w.project.Untrusted) > 0 {
+		// The files may have been trusted with witty trust since.
+		if err := w.projectTrust.Reload(); err != nil {
+			log.Debug().Msgf("failed to reload trusted projects: %v", err)
+		} else {
+			w.project = project.Resolve(files, w.projectTrust.Trusted)
+		}
+		w.notifyUntrusted(w.project.Untrusted)
+	}
+	w.useEngine(w.project.Engine)
+}
+
+// notifyUntrusted tells the user once about each untrusted file, and how to trust it. Trusting takes a
+// command rather than a key, so that it is never granted by a key meant for the shell.
+func (w *Witty) notifyUntrusted(untrusted []project.File) {
+	w.trustMu.Lock()
+	defer w.trustMu.Unlock()
+	for _, f := range untrusted {
+		if w.notifiedProjects[f.Path] != f.Digest {
+			w.notifiedProjects[f.Path] = f.Digest
+			w.setStatusMessage(fmt.Sprintf("witty: %s sets %s, run witty trust %s to apply them",
+				f.Path, strings.Join(f.Sensitive(), ", "), f.Path))
+			return
+		}
+	}
+}
+
+// useEngine switches to the engine with the given name, or back to the default one if name is empty.
+func (w *Witty) useEngine(name string) {
+	w.engineMu.Lock()
+	if name == "" {
+		name = w.defaultEngine
+	}
+	current := w.engineName
+	e, ok := w.projectEngines[name]
+	engines := w.engines
+	w.engineMu.Unlock()
+	if name == current {
+		return
+	}
+	if !ok {
+		suggestionEngine, builder, err := engines(name)
+		if errors.Is(err, engine.ErrUnauthenticated) {
+			// The engine is created once the user logs in to it.
+			message := fmt.Sprintf("witty: not logged in to %s, run witty login --engine %s", name, name)
+			if message != w.loginHint {
+				w.loginHint = message
+				w.setStatusMessage(message)
+			}
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to create engine %s", name)
+			w.setStatusMessage(fmt.Sprintf("witty: failed to switch to engine %s: %s", name, err))
+		}
+		e = projectEngine{suggestionEngine, builder}
+		w.engineMu.Lock()
+		w.projectEngines[name] = e
+		w.engineMu.Unlock()
+	}
+	if e.engine == nil {
+		return
+	}
+	log.Debug().Msgf("switching to engine %s", name)
+	w.loginHint = ""
+	w.setEngine(e.engine, e.builder, name)
+}
+
+// processDirTTL is how long the working directory of the shell process is reused for.
+const processDirTTL = 2 * time.Second
+
+// shellDirectory returns the working directory of the shell: the one it reported with OSC 7 if it is on this
+// host, or else the one of the shell process.
+func (w *Witty) shellDirectory() (string, error) {
+	w.terminalState.Lock()
+	reported := w.terminalState.WorkingDirectory()
+	w.terminalState.Unlock()
+	if u, err := url.Parse(reported); err == nil && u.Scheme == "file" && u.Path != "" && isLocalHost(u.Host) {
+		return u.Path, nil
+	}
+	if time.Since(w.processDirAt) < processDirTTL {
+		return w.processDir, nil
+	}
+	dir, err := processDirectory(w.shellPid)
+	if err != nil {
+		return "", err
+	}
+	w.processDir, w.processDirAt = dir, time.Now()
+	return dir, nil
+}
+
+// isLocalHost reports whether the host of a file URL is this host. Shells running over ssh report the
+// directories of the remote host.
+func isLocalHost(host string) bool {
+	if host == "" || host == "localhost" {
+		return true
+	}
+	hostname, err := os.Hostname()
+	return err == nil && strings.EqualFold(host, hostname)
+}
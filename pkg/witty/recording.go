@@ -0,0 +1,65 @@
+package witty
+
+import (
+	"os"
+	"strings"
+
+	"github.com/jjviana/codex/pkg/asciicast"
+)
+
+// Labels of the markers recorded for suggestion events, followed by the suggestion text.
+const (
+	MarkerSuggestionShown     = "suggestion shown: "
+	MarkerSuggestionAccepted  = "suggestion accepted: "
+	MarkerSuggestionDismissed = "suggestion dismissed: "
+	// MarkerSuggestionPartiallyAccepted is followed by the part of the suggestion accepted.
+	MarkerSuggestionPartiallyAccepted = "suggestion partially accepted: "
+)
+
+// shownWithProgram starts the suggestion shown markers that name the program in the foreground, as in
+// "suggestion shown (python3): print(".
+const shownWithProgram = "suggestion shown ("
+
+// ShownMarker returns the marker recorded when a suggestion is shown while program is in the foreground.
+func ShownMarker(program, text string) string {
+	if program == "" {
+		return MarkerSuggestionShown + text
+	}
+	return shownWithProgram + program + "): " + text
+}
+
+// ParseShownMarker returns the program and the suggestion of a suggestion shown marker, and false for other
+// markers. The program is empty in recordings made before it was recorded.
+func ParseShownMarker(marker string) (program, text string, ok bool) {
+	if strings.HasPrefix(marker, MarkerSuggestionShown) {
+		return "", strings.TrimPrefix(marker, MarkerSuggestionShown), true
+	}
+	if !strings.HasPrefix(marker, shownWithProgram) {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(marker, shownWithProgram)
+	i := strings.Index(rest, "): ")
+	if i < 0 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+3:], true
+}
+
+// SetRecorder records the session with the given recorder.
+func (w *Witty) SetRecorder(recorder *asciicast.Recorder) {
+	w.recorder = recorder
+}
+
+// recordingPty records the shell output read from the pty.
+type recordingPty struct {
+	*os.File
+	recorder *asciicast.Recorder
+}
+
+func (p recordingPty) Read(b []byte) (int, error) {
+	n, err := p.File.Read(b)
+	if n > 0 {
+		p.recorder.Output(b[:n])
+	}
+	return n, err
+}
@@ -0,0 +1,103 @@
+package witty
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/rs/zerolog/log"
+)
+
+// Options are the settings that can change while witty runs.
+type Options struct {
+	Color         tcell.Color
+	Keymap        Keymap
+	TriggerPolicy TriggerPolicy
+	Languages     engine.LanguageMap
+	Redaction     project.Rules
+	// Engine is the name of the engine to use where no project selects another one, created anew with
+	// Engines so that changes of its configuration apply.
+	Engine  string
+	Engines EngineFactory
+}
+
+// reloadEvent asks the main loop to apply new options, along with the engine created for them.
+type reloadEvent struct {
+	options Options
+	engine  projectEngine
+}
+
+// SetKeymap changes the keys witty handles itself.
+func (w *Witty) SetKeymap(keymap Keymap) {
+	w.settingsMu.Lock()
+	defer w.settingsMu.Unlock()
+	w.keymap = keymap
+}
+
+// SetRedaction changes the rules hiding parts of the prompts from the engine, beside those of projects.
+func (w *Witty) SetRedaction(rules project.Rules) {
+	w.settingsMu.Lock()
+	defer w.settingsMu.Unlock()
+	w.redaction = rules
+}
+
+func (w *Witty) currentKeymap() Keymap {
+	w.settingsMu.Lock()
+	defer w.settingsMu.Unlock()
+	return w.keymap
+}
+
+// Reload applies new options while witty runs. It may be called from any goroutine, which creates the engine
+// so that the main loop keeps handling the terminal meanwhile.
+func (w *Witty) Reload(options Options) {
+	if w.screen == nil {
+		// Not running yet.
+		return
+	}
+	suggestionEngine, builder, err := options.Engines(options.Engine)
+	if err != nil {
+		w.ReloadFailed(err)
+		return
+	}
+	event := reloadEvent{options, projectEngine{suggestionEngine, builder}}
+	if err := w.screen.PostEvent(tcell.NewEventInterrupt(event)); err != nil {
+		log.Error().Err(err).Msg("failed to reload configuration")
+	}
+}
+
+// ReloadFailed reports on the status line that the configuration could not be reloaded. The previous
+// configuration stays in effect.
+func (w *Witty) ReloadFailed(err error) {
+	log.Error().Err(err).Msg("failed to reload configuration")
+	w.setStatusMessage(fmt.Sprintf("witty: invalid configuration, not reloaded: %s", err))
+}
+
+// applyOptions applies reloaded options and swaps in the engine created for them. It runs in the main loop,
+// which reads the color and trigger policy.
+func (w *Witty) applyOptions(o Options, e projectEngine) {
+	w.suggestionColor = o.Color
+	w.triggerPolicy = o.TriggerPolicy
+	w.settingsMu.Lock()
+	w.keymap = o.Keymap
+	w.languages = o.Languages
+	w.redaction = o.Redaction
+	w.settingsMu.Unlock()
+
+	w.engineMu.Lock()
+	w.defaultEngine = o.Engine
+	w.engines = o.Engines
+	// Engines of projects are created again too, when next used.
+	w.projectEngines = map[string]projectEngine{o.Engine: e}
+	w.engineMu.Unlock()
+	if p := w.project.Engine; p == "" || p == o.Engine {
+		log.Debug().Msgf("swapping engine %s", o.Engine)
+		w.setEngine(e.engine, e.builder, o.Engine)
+	} else {
+		// The engine of the project is created again before the next request.
+		w.engineMu.Lock()
+		w.engineName = ""
+		w.engineMu.Unlock()
+	}
+	w.setStatusMessage("witty: configuration reloaded")
+}
@@ -0,0 +1,41 @@
+package witty
+
+import (
+	"strings"
+
+	"github.com/ActiveState/vt10x"
+	"github.com/jjviana/codex/pkg/scrollback"
+)
+
+// screenLine returns the content of the given screen row.
+func screenLine(state *vt10x.State, y int) string {
+	rows, cols := state.Size()
+	if y < 0 || y >= rows {
+		return ""
+	}
+	line := make([]rune, cols)
+	for x := 0; x < cols; x++ {
+		line[x], _, _ = state.Cell(x, y)
+	}
+	return string(line)
+}
+
+// lineSuffix returns the text of screen row y from column x on, without trailing blanks.
+func lineSuffix(state *vt10x.State, x, y int) string {
+	line := []rune(screenLine(state, y))
+	if x >= len(line) {
+		return ""
+	}
+	return strings.TrimRight(string(line[x:]), " \x00")
+}
+
+// promptText returns the terminal content before the cursor, preceded by the last scrollbackLines lines
+// that scrolled off the screen.
+func promptText(state *vt10x.State, sb *scrollback.Buffer, scrollbackLines int) string {
+	prompt := state.StringBeforeCursor()
+	if len(prompt) > 0 {
+		prompt = prompt[:len(prompt)-1] // remove the trailing newline inserted wrongly by the vt10x parser
+	}
+	// Earlier output that scrolled off the screen gives the engine more context.
+	return sb.Text(scrollbackLines) + prompt
+}
@@ -0,0 +1,413 @@
+package witty
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/jjviana/codex/pkg/scrollback"
+)
+
+// mouseWheelLines is how many lines a mouse wheel step scrolls.
+const mouseWheelLines = 3
+
+// position is a cell of the scrollback view: a line index and a column.
+type position struct {
+	line, col int
+}
+
+func (p position) before(other position) bool {
+	return p.line < other.line || (p.line == other.line && p.col < other.col)
+}
+
+// scrollView browses the scrollback buffer followed by the screen, as they were when the view was opened.
+// It supports paging, incremental regex search and copying a selection to the clipboard of the host
+// terminal with OSC 52. The bottom line of the screen is used as a status line.
+type scrollView struct {
+	lines  []scrollback.Line
+	top    int
+	height int
+	cursor position
+	// anchor is where the selection started; the selection spans from the anchor to the cursor.
+	anchor   *position
+	dragging bool
+	// searching is set while the search pattern is typed. The cursor and top line are restored to
+	// searchOrigin and searchTop if the search is cancelled.
+	searching    bool
+	query        []rune
+	pattern      *regexp.Regexp
+	searchOrigin position
+	searchTop    int
+	message      string
+}
+
+// newScrollView creates a view of the given lines, showing height lines at a time with the cursor at the given position.
+func newScrollView(lines []scrollback.Line, height int, cursor position) *scrollView {
+	v := &scrollView{lines: lines, cursor: cursor}
+	v.resize(height)
+	v.top = len(lines) - v.height
+	v.clampTop()
+	return v
+}
+
+// resize changes the screen height. The status line takes one line of it.
+func (v *scrollView) resize(height int) {
+	v.height = height - 1
+	if v.height < 1 {
+		v.height = 1
+	}
+	v.follow()
+}
+
+func (v *scrollView) clampTop() {
+	if v.top > len(v.lines)-v.height {
+		v.top = len(v.lines) - v.height
+	}
+	if v.top < 0 {
+		v.top = 0
+	}
+}
+
+// follow scrolls the view so that the cursor is visible.
+func (v *scrollView) follow() {
+	if v.cursor.line < v.top {
+		v.top = v.cursor.line
+	}
+	if v.cursor.line >= v.top+v.height {
+		v.top = v.cursor.line - v.height + 1
+	}
+	v.clampTop()
+}
+
+// moveCursor moves the cursor to the given position, clamped to the content.
+func (v *scrollView) moveCursor(p position) {
+	if p.line >= len(v.lines) {
+		p.line = len(v.lines) - 1
+	}
+	if p.line < 0 {
+		p.line = 0
+	}
+	if p.col < 0 {
+		p.col = 0
+	}
+	v.cursor = p
+	v.follow()
+}
+
+// scroll moves the view by the given number of lines, keeping the cursor within it.
+func (v *scrollView) scroll(lines int) {
+	v.top += lines
+	v.clampTop()
+	if v.cursor.line < v.top {
+		v.cursor.line = v.top
+	}
+	if v.cursor.line >= v.top+v.height {
+		v.cursor.line = v.top + v.height - 1
+	}
+}
+
+// handleKey reacts to a key press. It returns whether the view must be closed, and the text to copy to
+// the clipboard, if any.
+func (v *scrollView) handleKey(ev *tcell.EventKey) (bool, string) {
+	v.message = ""
+	if v.searching {
+		v.handleSearchKey(ev)
+		return false, ""
+	}
+
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		if v.anchor != nil {
+			v.anchor = nil
+			return false, ""
+		}
+		return true, ""
+	case tcell.KeyUp:
+		v.moveCursor(position{v.cursor.line - 1, v.cursor.col})
+	case tcell.KeyDown:
+		v.moveCursor(position{v.cursor.line + 1, v.cursor.col})
+	case tcell.KeyLeft:
+		v.moveCursor(position{v.cursor.line, v.cursor.col - 1})
+	case tcell.KeyRight:
+		v.moveCursor(position{v.cursor.line, v.cursor.col + 1})
+	case tcell.KeyPgUp, tcell.KeyCtrlB:
+		v.scroll(-v.height)
+	case tcell.KeyPgDn, tcell.KeyCtrlF:
+		v.scroll(v.height)
+	case tcell.KeyHome:
+		v.moveCursor(position{0, 0})
+	case tcell.KeyEnd:
+		v.moveCursor(position{len(v.lines) - 1, 0})
+	case tcell.KeyEnter:
+		return v.copySelection()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return true, ""
+		case 'k':
+			v.moveCursor(position{v.cursor.line - 1, v.cursor.col})
+		case 'j':
+			v.moveCursor(position{v.cursor.line + 1, v.cursor.col})
+		case 'h':
+			v.moveCursor(position{v.cursor.line, v.cursor.col - 1})
+		case 'l':
+			v.moveCursor(position{v.cursor.line, v.cursor.col + 1})
+		case 'b':
+			v.scroll(-v.height)
+		case ' ', 'f':
+			v.scroll(v.height)
+		case 'g':
+			v.moveCursor(position{0, 0})
+		case 'G':
+			v.moveCursor(position{len(v.lines) - 1, 0})
+		case '0':
+			v.moveCursor(position{v.cursor.line, 0})
+		case '$':
+			v.moveCursor(position{v.cursor.line, len(v.lines[v.cursor.line]) - 1})
+		case '/':
+			v.searching = true
+			v.query = nil
+			v.searchOrigin = v.cursor
+			v.searchTop = v.top
+		case 'n':
+			v.searchFrom(v.cursor, true)
+		case 'N':
+			v.searchFrom(position{v.cursor.line, v.cursor.col + 1}, false)
+		case 'v':
+			if v.anchor == nil {
+				anchor := v.cursor
+				v.anchor = &anchor
+			} else {
+				v.anchor = nil
+			}
+		case 'y':
+			return v.copySelection()
+		}
+	}
+	return false, ""
+}
+
+func (v *scrollView) handleSearchKey(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyEscape:
+		v.searching = false
+		v.cursor = v.searchOrigin
+		v.top = v.searchTop
+		return
+	case tcell.KeyEnter:
+		v.searching = false
+		return
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(v.query) > 0 {
+			v.query = v.query[:len(v.query)-1]
+		}
+	case tcell.KeyRune:
+		v.query = append(v.query, ev.Rune())
+	default:
+		return
+	}
+
+	// Incremental search: look for the pattern typed so far from where the search started.
+	v.cursor = v.searchOrigin
+	v.top = v.searchTop
+	if len(v.query) == 0 {
+		v.pattern = nil
+		return
+	}
+	pattern, err := regexp.Compile(string(v.query))
+	if err != nil {
+		v.message = "invalid pattern"
+		return
+	}
+	v.pattern = pattern
+	v.searchFrom(position{v.searchOrigin.line, v.searchOrigin.col + 1}, true)
+}
+
+// searchFrom moves the cursor to the closest match of the search pattern before from, when backward is set,
+// or after it.
+func (v *scrollView) searchFrom(from position, backward bool) {
+	if v.pattern == nil {
+		return
+	}
+	if backward {
+		for line := from.line; line >= 0; line-- {
+			matches := v.matches(line)
+			for i := len(matches) - 1; i >= 0; i-- {
+				if line < from.line || matches[i][0] < from.col {
+					v.moveCursor(position{line, matches[i][0]})
+					return
+				}
+			}
+		}
+	} else {
+		for line := from.line; line < len(v.lines); line++ {
+			for _, match := range v.matches(line) {
+				if line > from.line || match[0] >= from.col {
+					v.moveCursor(position{line, match[0]})
+					return
+				}
+			}
+		}
+	}
+	v.message = "pattern not found"
+}
+
+// matches returns the column ranges of the search pattern matches in the given line.
+func (v *scrollView) matches(line int) [][2]int {
+	if v.pattern == nil || line < 0 || line >= len(v.lines) {
+		return nil
+	}
+	text := v.lines[line].String()
+	var columns [][2]int
+	for _, m := range v.pattern.FindAllStringIndex(text, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		start := utf8.RuneCountInString(text[:m[0]])
+		columns = append(columns, [2]int{start, start + utf8.RuneCountInString(text[m[0]:m[1]])})
+	}
+	return columns
+}
+
+// handleMouse scrolls with the mouse wheel and selects text by dragging with the first button. It returns
+// the selected text when the button is released.
+func (v *scrollView) handleMouse(ev *tcell.EventMouse) string {
+	x, y := ev.Position()
+	buttons := ev.Buttons()
+	switch {
+	case buttons&tcell.WheelUp != 0:
+		v.scroll(-mouseWheelLines)
+	case buttons&tcell.WheelDown != 0:
+		v.scroll(mouseWheelLines)
+	case buttons&tcell.Button1 != 0:
+		if y >= v.height {
+			return ""
+		}
+		p := position{v.top + y, x}
+		if !v.dragging {
+			v.dragging = true
+			v.anchor = &p
+		}
+		v.moveCursor(p)
+	case buttons == tcell.ButtonNone && v.dragging:
+		v.dragging = false
+		if v.anchor != nil && *v.anchor != v.cursor {
+			_, text := v.copySelection()
+			return text
+		}
+		v.anchor = nil
+	}
+	return ""
+}
+
+// selection returns the ordered bounds of the selection, both included.
+func (v *scrollView) selection() (position, position, bool) {
+	if v.anchor == nil {
+		return position{}, position{}, false
+	}
+	start, end := *v.anchor, v.cursor
+	if end.before(start) {
+		start, end = end, start
+	}
+	return start, end, true
+}
+
+func (v *scrollView) selected(p position) bool {
+	start, end, ok := v.selection()
+	return ok && !p.before(start) && !end.before(p)
+}
+
+// copySelection returns the selected text and clears the selection. The view is closed after copying
+// with the keyboard.
+func (v *scrollView) copySelection() (bool, string) {
+	start, end, ok := v.selection()
+	if !ok {
+		v.message = "no selection, press v to start one"
+		return false, ""
+	}
+	v.anchor = nil
+	var lines []string
+	for line := start.line; line <= end.line; line++ {
+		text := []rune(v.lines[line].String())
+		from, to := 0, len(text)
+		if line == start.line && start.col < to {
+			from = start.col
+		}
+		if line == end.line && end.col+1 < to {
+			to = end.col + 1
+		}
+		if from > to {
+			from = to
+		}
+		lines = append(lines, strings.TrimRight(string(text[from:to]), " "))
+	}
+	return true, strings.Join(lines, "\n")
+}
+
+// draw renders the view and its status line.
+func (v *scrollView) draw(s tcell.Screen, width int) {
+	for row := 0; row < v.height; row++ {
+		index := v.top + row
+		var line scrollback.Line
+		if index < len(v.lines) {
+			line = v.lines[index]
+		}
+		matches := v.matches(index)
+		for x := 0; x < width; x++ {
+			c, style := ' ', tcell.StyleDefault
+			if x < len(line) {
+				c, style = line[x].Char, glyphStyle(line[x])
+				if c == 0 {
+					c = ' '
+				}
+			}
+			for _, m := range matches {
+				if x >= m[0] && x < m[1] {
+					style = style.Background(tcell.ColorYellow).Foreground(tcell.ColorBlack)
+				}
+			}
+			if v.selected(position{index, x}) {
+				style = style.Reverse(true)
+			}
+			s.SetContent(x, row, c, nil, style)
+		}
+	}
+	s.ShowCursor(v.cursor.col, v.cursor.line-v.top)
+	v.drawStatus(s, width)
+}
+
+func (v *scrollView) drawStatus(s tcell.Screen, width int) {
+	var status string
+	switch {
+	case v.searching:
+		status = "/" + string(v.query)
+		if v.message != "" {
+			status += " (" + v.message + ")"
+		}
+	case v.message != "":
+		status = v.message
+	default:
+		status = fmt.Sprintf("[%d/%d] arrows/PgUp/PgDn: scroll  /: search  n/N: next/previous  v: select  y: copy  q: quit",
+			v.top+v.height, len(v.lines))
+	}
+	text := []rune(status)
+	style := tcell.StyleDefault.Reverse(true)
+	for x := 0; x < width; x++ {
+		c := ' '
+		if x < len(text) {
+			c = text[x]
+		}
+		s.SetContent(x, v.height, c, nil, style)
+	}
+	if v.searching {
+		s.ShowCursor(len(text), v.height)
+	}
+}
+
+// osc52 returns the escape sequence that sets the clipboard of the host terminal to text.
+func osc52(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
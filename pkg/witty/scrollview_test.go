@@ -0,0 +1,66 @@
+package witty
+
+import (
+	"testing"
+
+	"github.com/ActiveState/vt10x"
+	"github.com/autarch/testify/assert"
+	"github.com/gdamore/tcell/v2"
+	"github.com/jjviana/codex/pkg/scrollback"
+)
+
+func testLines(texts ...string) []scrollback.Line {
+	var lines []scrollback.Line
+	for _, text := range texts {
+		var line scrollback.Line
+		for _, c := range text {
+			line = append(line, vt10x.Glyph{Char: c, FG: vt10x.DefaultFG, BG: vt10x.DefaultBG})
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func typeKeys(v *scrollView, keys string) {
+	for _, c := range keys {
+		v.handleKey(tcell.NewEventKey(tcell.KeyRune, c, tcell.ModNone))
+	}
+}
+
+func TestScrollViewIncrementalSearch(t *testing.T) {
+	lines := testLines("$ git diff", "+ añadido", "$ ls", "main.go", "$ ")
+	v := newScrollView(lines, 3, position{4, 2})
+	assert.Equal(t, 3, v.top, "view shows the bottom lines above the status line")
+
+	typeKeys(v, "/git")
+	assert.Equal(t, position{0, 2}, v.cursor, "incremental search moves to the match")
+	assert.Equal(t, 0, v.top, "view follows the match")
+	v.handleKey(tcell.NewEventKey(tcell.KeyEscape, 0, tcell.ModNone))
+	assert.Equal(t, position{4, 2}, v.cursor, "cancelled search restores the cursor")
+
+	typeKeys(v, "/^\\$")
+	v.handleKey(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+	assert.Equal(t, position{4, 0}, v.cursor)
+	typeKeys(v, "n")
+	assert.Equal(t, position{2, 0}, v.cursor, "n finds the previous match")
+	typeKeys(v, "n")
+	assert.Equal(t, position{0, 0}, v.cursor)
+	typeKeys(v, "n")
+	assert.Equal(t, "pattern not found", v.message)
+	typeKeys(v, "N")
+	assert.Equal(t, position{2, 0}, v.cursor, "N finds the next match")
+
+	typeKeys(v, "/dido")
+	assert.Equal(t, [][2]int{{5, 9}}, v.matches(1), "match columns count runes")
+}
+
+func TestScrollViewSelection(t *testing.T) {
+	lines := testLines("first line", "second line", "$ ")
+	v := newScrollView(lines, 10, position{0, 6})
+
+	typeKeys(v, "vj")
+	exit, text := v.handleKey(tcell.NewEventKey(tcell.KeyRune, 'y', tcell.ModNone))
+	assert.True(t, exit)
+	assert.Equal(t, "line\nsecond", text)
+	assert.Equal(t, "\x1b]52;c;bGluZQpzZWNvbmQ=\a", osc52(text))
+}
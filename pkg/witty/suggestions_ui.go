@@ -17,7 +17,8 @@ func (w *Witty) showCompletionsUI() {
 	}
 	app := tview.NewApplication()
 	list := tview.NewList()
-	choices, err := w.suggestionEngine.TopSuggestions(w.getPrompt(), w.currentSuggestion)
+	suggestionEngine, builder, _ := w.currentEngine()
+	choices, err := suggestionEngine.TopSuggestions(w.getRequest(builder), w.currentSuggestion)
 	if err != nil {
 		log.Debug().Msgf("error getting top suggestions: %v", err)
 		return
@@ -0,0 +1,74 @@
+package witty
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ActiveState/vt10x"
+)
+
+// TriggerPolicy decides when suggestions are requested.
+type TriggerPolicy struct {
+	// FullScreenAllowlist lists the programs that keep getting suggestions while they use the alternate
+	// screen or application cursor keys, such as REPLs built on full-screen line editors. Suggestions are
+	// paused for any other full-screen program, like editors, pagers or htop.
+	FullScreenAllowlist []string
+}
+
+// DefaultTriggerPolicy returns the policy used when none is configured.
+func DefaultTriggerPolicy() TriggerPolicy {
+	return TriggerPolicy{}
+}
+
+// triggerPolicyName is the name the policy is stored under in the configuration repository.
+const triggerPolicyName = "trigger"
+
+type configRepository interface {
+	Load(name string, config interface{}) error
+}
+
+// LoadTriggerPolicy loads the trigger policy from the configuration repository. Settings missing from the
+// stored policy keep their default value.
+func LoadTriggerPolicy(configRepository configRepository) (TriggerPolicy, error) {
+	policy := DefaultTriggerPolicy()
+	err := configRepository.Load(triggerPolicyName, &policy)
+	if err != nil && !os.IsNotExist(err) {
+		return policy, fmt.Errorf("failed to load trigger policy: %w", err)
+	}
+	return policy, nil
+}
+
+func (p TriggerPolicy) allowsFullScreen(program string) bool {
+	for _, allowed := range p.FullScreenAllowlist {
+		if allowed == program {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTriggerPolicy changes the policy deciding when suggestions are requested.
+func (w *Witty) SetTriggerPolicy(policy TriggerPolicy) {
+	w.triggerPolicy = policy
+}
+
+// suggestionsPaused reports whether the program in the foreground drives the terminal as a full-screen
+// application, in which case its screen is not a prompt and ghost text would be painted over it.
+func (w *Witty) suggestionsPaused() bool {
+	if !w.fullScreen() {
+		return false
+	}
+	program, ok := w.foregroundProgram()
+	return !ok || !w.triggerPolicy.allowsFullScreen(program)
+}
+
+// fullScreen reports whether the alternate screen is in use (DECSET 47, 1047 or 1049) or, for programs
+// other than the shell, whether application cursor keys are enabled (DECCKM). Line editors such as zle
+// enable application cursor keys while editing, so the shell itself is not considered full-screen.
+func (w *Witty) fullScreen() bool {
+	w.terminalState.Lock()
+	altScreen := w.terminalState.Mode(vt10x.ModeAltScreen)
+	appCursor := w.terminalState.Mode(vt10x.ModeAppCursor)
+	w.terminalState.Unlock()
+	return altScreen || (appCursor && w.foregroundPgrp != w.shellPid)
+}
@@ -1,11 +1,19 @@
 package witty
 
 import (
+	"errors"
 	"fmt"
+	"github.com/jjviana/codex/pkg/analytics"
+	"github.com/jjviana/codex/pkg/asciicast"
 	"github.com/jjviana/codex/pkg/engine"
+	"github.com/jjviana/codex/pkg/project"
+	"github.com/jjviana/codex/pkg/prompt"
+	"github.com/jjviana/codex/pkg/scrollback"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ActiveState/vt10x"
@@ -25,22 +33,99 @@ type Witty struct {
 	shellArgs         []string
 	wittyState        int
 	currentSuggestion engine.Suggestion
-	suggestionEngine  engine.SuggestionEngine
-	terminalState     vt10x.State
-	vterm             *vt10x.VT
-	screen            tcell.Screen
-	shellPty          *os.File
-	suggestionColor   tcell.Color
-	updateTrigger     chan struct{}
+	// engineMu guards the engine, the builder of its prompts and its name, which are swapped together when
+	// the configuration changes, along with the engines of projects. Requests in flight complete with the
+	// engine they started with.
+	engineMu         sync.Mutex
+	suggestionEngine engine.SuggestionEngine
+	promptBuilder    *prompt.Builder
+	engineName       string
+	terminalState    vt10x.State
+	vterm            *vt10x.VT
+	screen           tcell.Screen
+	shellPty         *os.File
+	suggestionColor  tcell.Color
+	updateTrigger    chan struct{}
+	triggerPolicy    TriggerPolicy
+	// settingsMu guards the settings that are read outside of the main loop and change when the
+	// configuration is reloaded.
+	settingsMu sync.Mutex
+	keymap     Keymap
+	languages  engine.LanguageMap
+	// redaction hides the matches of the configured rules from the engine, along with those of the project.
+	redaction      project.Rules
+	shellPid       int
+	foregroundPgrp int
+	// scrollback keeps the lines that scrolled off the screen, the last scrollbackPromptLines of which
+	// are offered to the prompt builder.
+	scrollback            *scrollback.Buffer
+	scrollbackPromptLines int
+	// sessionMarker is the screen line where the session of the current foreground program started.
+	// It is empty when the shell itself is in the foreground.
+	sessionMarker string
+	// backoffUntil is the time before which no suggestions are requested, after the engine failed.
+	backoffUntil time.Time
+	// engineDisabled is set when the engine failed in a way that retrying cannot fix.
+	engineDisabled bool
+	// needsLogin is set when the engine credentials are no longer valid and the user can log in again.
+	needsLogin bool
+	// statusMessage is shown on the bottom line of the screen until the next key press.
+	statusMessage string
+	// scrollMode is set from the moment the scrollback view hotkey is pressed until the view is closed.
+	// Input is not sent to the shell meanwhile.
+	scrollMode bool
+	scrollView *scrollView
+	recorder   *asciicast.Recorder
+	analytics  *analytics.Log
+	// suggestionEngineName is the engine that made the current suggestion, suggestionLatency how long it
+	// took and suggestionProgram the program in the foreground when it was requested.
+	suggestionEngineName string
+	suggestionLatency    time.Duration
+	suggestionProgram    string
+	// project is the configuration of the directory the shell is in, when projects are set up.
+	projectTrust *project.Trust
+	projectFiles *project.Cache
+	project      project.Project
+	projectError string
+	// processDir is the working directory of the shell process, read at processDirAt. It is only read again
+	// once it is older than processDirTTL, as reading it starts lsof on macOS.
+	processDir   string
+	processDirAt time.Time
+	// loginHint is the last message asking to log in to the engine of a project, shown once.
+	loginHint string
+	// trustMu guards notifiedProjects, which maps the untrusted files the user was told about to their digest.
+	trustMu          sync.Mutex
+	notifiedProjects map[string]string
+	// defaultEngine is used where no project selects another engine; engines creates the engines projects
+	// select, which are kept in projectEngines.
+	defaultEngine  string
+	engines        EngineFactory
+	projectEngines map[string]projectEngine
 }
 
-func New(engine engine.SuggestionEngine, color tcell.Color, shell string, args []string) *Witty {
+// openScrollViewEvent asks the main loop to open the scrollback view.
+type openScrollViewEvent struct{}
+
+// defaultBackoff is how long to wait before requesting suggestions again after a transient engine failure,
+// when the engine does not say.
+const defaultBackoff = 30 * time.Second
+
+func New(suggestionEngine engine.SuggestionEngine, color tcell.Color, shell string, args []string,
+	languages engine.LanguageMap, promptBuilder *prompt.Builder, scrollbackConfig scrollback.Config) *Witty {
 	w := &Witty{
 		wittyState:       StateNormal,
-		suggestionEngine: engine,
+		suggestionEngine: suggestionEngine,
 		suggestionColor:  color,
 		shellCommand:     shell,
 		shellArgs:        args,
+		languages:        languages,
+		triggerPolicy:    DefaultTriggerPolicy(),
+		keymap:           DefaultKeymap(),
+		promptBuilder:    promptBuilder,
+		project:          project.Project{Enabled: true},
+
+		scrollback:            scrollbackConfig.NewBuffer(),
+		scrollbackPromptLines: scrollbackConfig.PromptLines,
 	}
 
 	return w
@@ -57,9 +142,16 @@ func (w *Witty) Run() error {
 	}
 	// Make sure to close the pty at the end.
 	defer func() { _ = w.shellPty.Close() }() // Best effort.
+	w.shellPid = c.Process.Pid
+	w.foregroundPgrp = w.shellPid
 
 	// Create the virtual terminal to interpret the shell output
-	w.vterm, err = vt10x.Create(&w.terminalState, w.shellPty)
+	w.terminalState.OnScrollOut = w.scrollback.Push
+	var shellOutput io.ReadWriteCloser = w.shellPty
+	if w.recorder != nil {
+		shellOutput = recordingPty{File: w.shellPty, recorder: w.recorder}
+	}
+	w.vterm, err = vt10x.Create(&w.terminalState, shellOutput)
 	if err != nil {
 		return err
 	}
@@ -85,6 +177,12 @@ func (w *Witty) Run() error {
 	go w.stdinToShellLoop(stdInChan)
 
 	width, height := w.screen.Size()
+	if w.recorder != nil {
+		env := map[string]string{"SHELL": w.shellCommand, "TERM": os.Getenv("TERM")}
+		if err := w.recorder.Start(width, height, env); err != nil {
+			return err
+		}
+	}
 
 	vt10x.ResizePty(w.shellPty, width, height)
 	w.vterm.Resize(width, height)
@@ -95,13 +193,16 @@ func (w *Witty) Run() error {
 		defer close(endc)
 		// Parses the shell output
 		for {
+			_, row := w.terminalState.Cursor()
 			err := w.vterm.Parse()
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				break
 			}
+			w.trackForegroundProcess(row)
 			if w.wittyState == StateSuggesting {
 				// Reset the state as output has change
+				w.suggestionEvent(analytics.Dismissed, w.currentSuggestion, 0)
 				w.wittyState = StateNormal
 				w.currentSuggestion = nil
 			}
@@ -124,10 +225,31 @@ func (w *Witty) Run() error {
 			switch ev := event.(type) {
 			case *tcell.EventResize:
 				width, height = ev.Size()
+				if w.recorder != nil {
+					w.recorder.Resize(width, height)
+				}
 				log.Debug().Msgf("Resize: %d x %d", width, height)
 				vt10x.ResizePty(w.shellPty, width, height)
 				w.vterm.Resize(width, height)
+				if w.scrollView != nil {
+					w.scrollView.resize(height)
+				}
 				w.screen.Sync()
+			case *tcell.EventInterrupt:
+				switch data := ev.Data().(type) {
+				case openScrollViewEvent:
+					w.openScrollView(height)
+				case reloadEvent:
+					w.applyOptions(data.options, data.engine)
+				}
+			case *tcell.EventKey:
+				if w.scrollView != nil {
+					w.scrollViewDone(w.scrollView.handleKey(ev))
+				}
+			case *tcell.EventMouse:
+				if w.scrollView != nil {
+					w.scrollViewDone(false, w.scrollView.handleMouse(ev))
+				}
 			}
 		case <-endc:
 			return nil
@@ -136,8 +258,9 @@ func (w *Witty) Run() error {
 			w.updateScreen(w.screen, &w.terminalState, width, height)
 
 		case <-time.After(1 * time.Second):
-			log.Debug().Msg("shell is idle, state is " + string(w.wittyState))
-			if w.wittyState == StateNormal {
+			log.Debug().Msgf("shell is idle, state is %d", w.wittyState)
+			if w.wittyState == StateNormal && !w.engineDisabled && !w.scrollMode && time.Now().After(w.backoffUntil) &&
+				!w.suggestionsPaused() {
 				w.wittyState = StateFetchingSuggestions
 				go w.fetchSuggestions()
 			}
@@ -153,13 +276,116 @@ func (w *Witty) triggerScreenUpdate() {
 	}
 }
 
+// trackForegroundProcess detects changes in the process group running in the foreground of the shell pty.
+// When a program other than the shell takes over the terminal, the line the cursor was at before the last
+// output was parsed is recorded so that prompts can be trimmed to the program session.
+func (w *Witty) trackForegroundProcess(previousRow int) {
+	pgrp, err := foregroundProcessGroup(w.shellPty)
+	if err != nil || pgrp == w.foregroundPgrp {
+		return
+	}
+	log.Debug().Msgf("foreground process group changed from %d to %d", w.foregroundPgrp, pgrp)
+	w.foregroundPgrp = pgrp
+	if pgrp == w.shellPid {
+		w.sessionMarker = ""
+		return
+	}
+	marker := screenLine(&w.terminalState, previousRow)
+	if strings.TrimSpace(marker) == "" && previousRow > 0 {
+		// The command line echo was already parsed, the cursor is on a fresh line.
+		marker = screenLine(&w.terminalState, previousRow-1)
+	}
+	w.sessionMarker = marker
+}
+
+// foregroundProgram returns the name of the program currently in the foreground of the shell pty.
+func (w *Witty) foregroundProgram() (string, bool) {
+	pgrp := w.foregroundPgrp
+	name, err := processName(pgrp)
+	if err != nil {
+		log.Debug().Msgf("failed to determine name of process %d: %v", pgrp, err)
+		return "", false
+	}
+	return name, true
+}
+
+// foregroundLanguage returns the language of the program currently in the foreground of the shell pty.
+func (w *Witty) foregroundLanguage() engine.Language {
+	if w.foregroundPgrp == w.shellPid {
+		return engine.Shell
+	}
+	name, ok := w.foregroundProgram()
+	if !ok {
+		return engine.Shell
+	}
+	w.settingsMu.Lock()
+	language, ok := w.languages.Lookup(name)
+	w.settingsMu.Unlock()
+	if !ok {
+		log.Debug().Msgf("no language mapped for program %s", name)
+	}
+	return language
+}
+
+// currentEngine returns the engine suggestions are requested from, the builder of its prompts and its name.
+func (w *Witty) currentEngine() (engine.SuggestionEngine, *prompt.Builder, string) {
+	w.engineMu.Lock()
+	defer w.engineMu.Unlock()
+	return w.suggestionEngine, w.promptBuilder, w.engineName
+}
+
+// setEngine swaps the engine suggestions are requested from. The state of the previous engine does not
+// carry over.
+func (w *Witty) setEngine(e engine.SuggestionEngine, builder *prompt.Builder, name string) {
+	w.engineMu.Lock()
+	w.suggestionEngine, w.promptBuilder, w.engineName = e, builder, name
+	w.engineMu.Unlock()
+	w.engineDisabled, w.needsLogin, w.backoffUntil = false, false, time.Time{}
+}
+
+func (w *Witty) getRequest(builder *prompt.Builder) engine.Request {
+	w.settingsMu.Lock()
+	redaction := w.redaction
+	w.settingsMu.Unlock()
+	preamble := w.project.Preamble
+	suffix := redaction.Redact(w.project.Redact(w.getSuffix()))
+	prompt := redaction.Redact(w.project.Redact(w.getPrompt(builder, preamble, suffix)))
+	if prompt != "" && preamble != "" {
+		prompt = preamble + "\n" + prompt
+	}
+	return engine.Request{
+		Prompt:   prompt,
+		Suffix:   suffix,
+		Language: w.foregroundLanguage(),
+	}
+}
+
+// getSuffix returns the text after the cursor on the current input line.
+func (w *Witty) getSuffix() string {
+	w.terminalState.Lock()
+	defer w.terminalState.Unlock()
+	x, y := w.terminalState.Cursor()
+	return lineSuffix(&w.terminalState, x, y)
+}
+
 func (w *Witty) fetchSuggestions() {
-	prompt := w.getPrompt()
-	if len(prompt) > 0 {
-		log.Debug().Msgf("prompt: %s", prompt)
-		suggestion, err := w.suggestionEngine.Suggest(prompt)
+	w.updateProject()
+	if !w.project.Enabled {
+		w.wittyState = StateNormal
+		w.currentSuggestion = nil
+		return
+	}
+	suggestionEngine, builder, engineName := w.currentEngine()
+	request := w.getRequest(builder)
+	if len(request.Prompt) > 0 {
+		log.Debug().Msgf("prompt: %s", request.Prompt)
+		program, _ := w.foregroundProgram()
+		start := time.Now()
+		suggestion, err := suggestionEngine.Suggest(request)
+		latency := time.Since(start)
 		if err != nil {
 			log.Error().Err(err).Msg("error fetching suggestion")
+			w.handleEngineError(suggestionEngine, err)
 			w.wittyState = StateNormal
 			w.currentSuggestion = nil
 			return
@@ -167,6 +393,10 @@ func (w *Witty) fetchSuggestions() {
 		if w.wittyState == StateFetchingSuggestions { // someone else might have already changed the state
 			w.wittyState = StateSuggesting
 			w.currentSuggestion = suggestion
+			w.suggestionEngineName = engineName
+			w.suggestionLatency = latency
+			w.suggestionProgram = program
+			w.suggestionEvent(analytics.Shown, suggestion, 0)
 			w.triggerScreenUpdate()
 		}
 	} else {
@@ -175,31 +405,120 @@ func (w *Witty) fetchSuggestions() {
 	}
 }
 
-func (w *Witty) getPrompt() string {
-	prompt := w.terminalState.StringBeforeCursor()
-	if len(prompt) > 0 {
-		prompt = prompt[:len(prompt)-1] // remove the trailing newline inserted wrongly by the vt10x parser
+// handleEngineError reacts to a failed suggestion request: transient failures pause suggestions for a while,
+// while authentication and quota failures disable the engine until the user intervenes.
+func (w *Witty) handleEngineError(suggestionEngine engine.SuggestionEngine, err error) {
+	switch {
+	case errors.Is(err, engine.ErrRateLimited), errors.Is(err, engine.ErrUnavailable):
+		backoff, ok := engine.RetryAfter(err)
+		if !ok {
+			backoff = defaultBackoff
+		}
+		log.Debug().Msgf("pausing suggestions for %s", backoff)
+		w.backoffUntil = time.Now().Add(backoff)
+	case errors.Is(err, engine.ErrUnauthenticated):
+		w.engineDisabled = true
+		if _, ok := suggestionEngine.(engine.Authenticator); ok {
+			w.needsLogin = true
+			w.setStatusMessage(fmt.Sprintf("witty: engine authentication failed, press %s to log in again",
+				keyLabel(w.currentKeymap().Suggestions)))
+		} else {
+			w.setStatusMessage("witty: engine authentication failed, suggestions disabled")
+		}
+	case errors.Is(err, engine.ErrQuotaExceeded):
+		w.engineDisabled = true
+		w.setStatusMessage("witty: engine quota exceeded, suggestions disabled")
 	}
-	return prompt
+}
+
+func (w *Witty) setStatusMessage(message string) {
+	w.statusMessage = message
+	w.triggerScreenUpdate()
+}
+
+// login suspends the screen while the engine logs in again.
+func (w *Witty) login() {
+	suggestionEngine, _, _ := w.currentEngine()
+	authenticator, ok := suggestionEngine.(engine.Authenticator)
+	if !ok {
+		return
+	}
+	w.screen.Suspend()
+	err := authenticator.Login()
+	w.screen.Resume()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to log in")
+		w.setStatusMessage(fmt.Sprintf("witty: login failed: %s", err))
+		return
+	}
+	w.needsLogin = false
+	w.engineDisabled = false
+	w.setStatusMessage("")
+}
+
+// getPrompt returns the terminal content that fits the budget of the builder, leaving room for the other
+// texts of the request.
+func (w *Witty) getPrompt(builder *prompt.Builder, others ...string) string {
+	prompt := promptText(&w.terminalState, w.scrollback, w.scrollbackPromptLines)
+	if marker := w.sessionMarker; marker != "" {
+		// Only send the session of the program in the foreground, not the shell history preceding it.
+		if i := strings.LastIndex(prompt, marker+"\n"); i >= 0 {
+			prompt = prompt[i+len(marker)+1:]
+		}
+	}
+	return builder.Build(prompt, others...)
+}
+
+// openScrollView shows the scrollback buffer followed by the screen content.
+func (w *Witty) openScrollView(height int) {
+	w.terminalState.Lock()
+	// Lines are only added to the scrollback buffer while the terminal state is locked.
+	lines := w.scrollback.Lines()
+	rows, cols := w.terminalState.Size()
+	for y := 0; y < rows; y++ {
+		line := make(scrollback.Line, cols)
+		for x := range line {
+			line[x] = w.terminalState.Glyph(x, y)
+		}
+		lines = append(lines, line)
+	}
+	curx, cury := w.terminalState.Cursor()
+	w.terminalState.Unlock()
+
+	w.scrollView = newScrollView(lines, height, position{line: len(lines) - rows + cury, col: curx})
+	w.screen.EnableMouse()
+	w.triggerScreenUpdate()
+}
+
+// scrollViewDone copies text, if any, to the clipboard of the host terminal and closes the scrollback
+// view if requested.
+func (w *Witty) scrollViewDone(exit bool, text string) {
+	if text != "" {
+		if _, err := os.Stdout.WriteString(osc52(text)); err != nil {
+			log.Error().Err(err).Msg("failed to copy selection")
+		}
+	}
+	if exit {
+		w.scrollView = nil
+		w.screen.DisableMouse()
+		w.scrollMode = false
+	}
+	w.triggerScreenUpdate()
 }
 
 func (w *Witty) updateScreen(s tcell.Screen, state *vt10x.State, width, height int) {
+	if w.scrollView != nil {
+		w.scrollView.draw(s, width)
+		s.Show()
+		return
+	}
 	state.Lock()
 	defer state.Unlock()
 	log.Debug().Msgf("updating screen, width: %d, height: %d", width, height)
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
-			c, fg, bg := state.Cell(x, y)
-
-			style := tcell.StyleDefault
-			if fg != vt10x.DefaultFG {
-				style = style.Foreground(tcell.Color(fg))
-			}
-			if bg != vt10x.DefaultBG {
-				style = style.Background(tcell.Color(bg))
-			}
-
-			s.SetContent(x, y, c, nil, style)
+			g := state.Glyph(x, y)
+			s.SetContent(x, y, g.Char, nil, glyphStyle(g))
 
 		}
 	}
@@ -209,36 +528,122 @@ func (w *Witty) updateScreen(s tcell.Screen, state *vt10x.State, width, height i
 		if w.currentSuggestion != nil && w.currentSuggestion.Text() != "" {
 			text := strings.TrimRight(w.currentSuggestion.Text(), " ")
 			style := tcell.StyleDefault.Foreground(w.suggestionColor)
-			x := curx
-			y := cury
-			for i := 0; i < len(text); i++ {
-				if text[i] == '\n' {
-					y++
-					x = 0
+			x, y := drawGhostText(s, text, curx, cury, width, height, style)
+			if !strings.Contains(text, "\n") {
+				// The suggestion is inserted at the cursor: shift the rest of the input line after it.
+				suffix := len([]rune(lineSuffix(state, curx, cury)))
+				for i := curx; i < curx+suffix; i++ {
+					if x >= width {
+						x, y = 0, y+1
+					}
+					if y >= height {
+						break
+					}
+					g := state.Glyph(i, cury)
+					s.SetContent(x, y, g.Char, nil, glyphStyle(g))
+					x++
 				}
-				s.SetContent(x, y, rune(text[i]), nil, style)
-				x++
 			}
 		}
 	} else {
 		s.HideCursor()
 	}
+	if w.statusMessage != "" {
+		w.drawStatusMessage(s, width, height)
+	}
 	s.Show()
 }
 
+// drawStatusMessage draws the status message right aligned on the bottom line of the screen.
+func (w *Witty) drawStatusMessage(s tcell.Screen, width, height int) {
+	message := []rune(w.statusMessage)
+	if len(message) > width {
+		message = message[:width]
+	}
+	style := tcell.StyleDefault.Foreground(w.suggestionColor).Reverse(true)
+	x := width - len(message)
+	for i, c := range message {
+		s.SetContent(x+i, height-1, c, nil, style)
+	}
+}
+
+// glyphStyle returns the tcell style rendering a vt10x cell: its colors and graphic rendition attributes.
+func glyphStyle(g vt10x.Glyph) tcell.Style {
+	return tcell.StyleDefault.
+		Foreground(tcellColor(g.FG)).
+		Background(tcellColor(g.BG)).
+		Bold(g.Mode&vt10x.AttrBold != 0).
+		Dim(g.Mode&vt10x.AttrDim != 0).
+		Italic(g.Mode&vt10x.AttrItalic != 0).
+		Underline(g.Mode&vt10x.AttrUnderline != 0).
+		Blink(g.Mode&vt10x.AttrBlink != 0).
+		Reverse(g.Mode&vt10x.AttrReverse != 0)
+}
+
+// tcellColor maps a vt10x color to tcell. The vt10x default color sentinels map to the default colors of
+// the host terminal.
+func tcellColor(c vt10x.Color) tcell.Color {
+	if r, g, b, ok := c.RGB(); ok {
+		return tcell.NewRGBColor(int32(r), int32(g), int32(b))
+	}
+	if c < 256 {
+		return tcell.PaletteColor(int(c))
+	}
+	return tcell.ColorDefault
+}
+
 func (w *Witty) stdinToShellLoop(stdin chan []byte) {
 	for data := range stdin {
 		log.Debug().Msgf("stdin: %+v", data)
+		if w.recorder != nil {
+			w.recorder.Input(data)
+		}
+		if w.scrollMode {
+			// The scrollback view handles the input through screen events.
+			continue
+		}
+		keymap := w.currentKeymap()
+		if match(keymap.Scrollback, data) > 0 {
+			w.scrollMode = true
+			if err := w.screen.PostEvent(tcell.NewEventInterrupt(openScrollViewEvent{})); err != nil {
+				log.Error().Err(err).Msg("failed to open scrollback view")
+				w.scrollMode = false
+			}
+			continue
+		}
+		if w.needsLogin && match(keymap.Suggestions, data) > 0 {
+			w.login()
+			continue
+		}
+		if w.statusMessage != "" {
+			w.setStatusMessage("")
+		}
 		switch w.wittyState {
 		case StateSuggesting:
-			if data[0] == '\t' && w.currentSuggestion != nil && len(w.currentSuggestion.Text()) > 0 {
+			if n := match(keymap.Accept, data); n > 0 && w.currentSuggestion != nil && len(w.currentSuggestion.Text()) > 0 {
 				_, err := w.shellPty.Write([]byte(w.currentSuggestion.Text()))
 				if err != nil {
 					log.Error().Err(err).Msg("failed to write to shell")
 					os.Exit(1)
 				}
-				data = data[1:]
-			} else if data[0] == 15 { // ctrl-o
+				w.suggestionEvent(analytics.Accepted, w.currentSuggestion, len(w.currentSuggestion.Text()))
+				data = data[n:]
+			} else if n := match(keymap.AcceptWord, data); n > 0 && w.currentSuggestion != nil && len(w.currentSuggestion.Text()) > 0 {
+				// The rest of the suggestion is dropped; the next one is requested when the terminal is idle.
+				text := w.currentSuggestion.Text()
+				accepted := nextWord(text)
+				_, err := w.shellPty.Write([]byte(text[:accepted]))
+				if err != nil {
+					log.Error().Err(err).Msg("failed to write to shell")
+					os.Exit(1)
+				}
+				eventType := analytics.PartiallyAccepted
+				if accepted == len(text) {
+					eventType = analytics.Accepted
+				}
+				w.suggestionEvent(eventType, w.currentSuggestion, accepted)
+				data = data[n:]
+			} else if match(keymap.Suggestions, data) > 0 {
 				log.Debug().Msgf("Suspending normal UI...")
 				w.screen.Suspend()
 				w.showCompletionsUI()
@@ -246,6 +651,8 @@ func (w *Witty) stdinToShellLoop(stdin chan []byte) {
 				log.Debug().Msgf("Resumed from suggestions UI")
 				w.triggerScreenUpdate()
 				continue
+			} else {
+				w.suggestionEvent(analytics.Dismissed, w.currentSuggestion, 0)
 			}
 
 			w.wittyState = StateNormal
@@ -255,6 +662,9 @@ func (w *Witty) stdinToShellLoop(stdin chan []byte) {
 			w.wittyState = StateNormal
 			w.currentSuggestion = nil
 		}
+		if len(data) == 0 {
+			continue
+		}
 		_, err := w.shellPty.Write(data)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to write to shell")
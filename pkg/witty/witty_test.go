@@ -0,0 +1,69 @@
+package witty
+
+import (
+	"testing"
+
+	"github.com/ActiveState/vt10x"
+	"github.com/autarch/testify/assert"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestGlyphStyle(t *testing.T) {
+	g := vt10x.Glyph{
+		Char: 'x',
+		Mode: vt10x.AttrBold | vt10x.AttrUnderline | vt10x.AttrReverse,
+		FG:   vt10x.RGB(255, 0, 128),
+		BG:   vt10x.DefaultBG,
+	}
+	fg, bg, attrs := glyphStyle(g).Decompose()
+	assert.Equal(t, tcell.NewRGBColor(255, 0, 128), fg)
+	assert.Equal(t, tcell.ColorDefault, bg, "default background sentinel")
+	assert.Equal(t, tcell.AttrBold|tcell.AttrUnderline|tcell.AttrReverse, attrs)
+
+	g = vt10x.Glyph{Char: 'y', Mode: vt10x.AttrDim | vt10x.AttrItalic | vt10x.AttrBlink, FG: 196, BG: vt10x.Blue}
+	fg, bg, attrs = glyphStyle(g).Decompose()
+	assert.Equal(t, tcell.PaletteColor(196), fg)
+	assert.Equal(t, tcell.PaletteColor(4), bg)
+	assert.Equal(t, tcell.AttrDim|tcell.AttrItalic|tcell.AttrBlink, attrs)
+}
+
+func TestFullScreen(t *testing.T) {
+	w := &Witty{shellPid: 10, foregroundPgrp: 10}
+
+	w.terminalState.WriteString("$ ls", 4, 10)
+	assert.False(t, w.fullScreen())
+
+	w.terminalState.WriteString("\033[?1h$ ls", 4, 10)
+	assert.False(t, w.fullScreen(), "application cursor keys of the shell line editor")
+	w.foregroundPgrp = 11
+	assert.True(t, w.fullScreen(), "application cursor keys of another program")
+
+	w.foregroundPgrp = 10
+	w.terminalState.WriteString("\033[?1049hvim", 4, 10)
+	assert.True(t, w.fullScreen(), "alternate screen")
+	w.terminalState.WriteString("\033[?1049h\033[?1049l", 4, 10)
+	assert.False(t, w.fullScreen(), "alternate screen left")
+}
+
+func TestNextWord(t *testing.T) {
+	assert.Equal(t, 3, nextWord("git status"))
+	assert.Equal(t, 6, nextWord("status"))
+	assert.Equal(t, 7, nextWord(" status --short"))
+	assert.Equal(t, 1, nextWord("\nls"))
+}
+
+func TestKeymap(t *testing.T) {
+	keymap := DefaultKeymap()
+	assert.Equal(t, 1, match(keymap.Accept, []byte("\tls")))
+	assert.Equal(t, 2, match(keymap.AcceptWord, []byte("\x1bf")))
+	assert.Equal(t, 6, match(keymap.AcceptWord, []byte("\x1b[1;5C")))
+	assert.Equal(t, 1, match(keymap.Scrollback, []byte{0x1d}))
+	assert.Equal(t, 0, match(keymap.Suggestions, []byte("o")))
+	assert.Equal(t, 3, match([]string{"right"}, []byte("\x1bOC")), "application cursor keys")
+	assert.Equal(t, 0, match([]string{"esc"}, []byte("\x1b[A")), "escape only matches by itself")
+	assert.Equal(t, "Ctrl-O", keyLabel(keymap.Suggestions))
+	assert.Equal(t, "Ctrl-]", keyLabel(keymap.Scrollback))
+
+	_, err := keySequences("hyper-x")
+	assert.NotNil(t, err)
+}
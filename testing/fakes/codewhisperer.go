@@ -0,0 +1,315 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const generateCompletionsTarget = "AmazonCodeWhispererService.GenerateCompletions"
+
+// CodeWhisperer fakes the CodeWhisperer JSON-RPC service along with the SSO OIDC endpoints used to
+// log in with an AWS Builder ID. Both are served from the same URL.
+type CodeWhisperer struct {
+	*httptest.Server
+	script
+
+	mu            sync.Mutex
+	completions   []Completion
+	pendingPolls  int
+	polls         map[string]int
+	clients       map[string]string
+	accessTokens  map[string]bool
+	refreshTokens map[string]bool
+	issued        int
+	requests      []CodeWhispererRequest
+}
+
+// Completion is a completion returned by the CodeWhisperer fake.
+type Completion struct {
+	Content    string      `json:"content"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Reference is a code reference attached to a completion.
+type Reference struct {
+	LicenseName string `json:"licenseName,omitempty"`
+	Repository  string `json:"repository,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// CodeWhispererRequest is a GenerateCompletions request received by the CodeWhisperer fake.
+type CodeWhispererRequest struct {
+	FileContext struct {
+		Filename            string `json:"filename"`
+		LeftFileContent     string `json:"leftFileContent"`
+		RightFileContent    string `json:"rightFileContent"`
+		ProgrammingLanguage struct {
+			LanguageName string `json:"languageName"`
+		} `json:"programmingLanguage"`
+	} `json:"fileContext"`
+	MaxResults int    `json:"maxResults"`
+	NextToken  string `json:"nextToken"`
+	// Token is the bearer token the request was authorized with.
+	Token string `json:"-"`
+}
+
+// NewCodeWhisperer starts a CodeWhisperer fake that answers every request with a single "ls -la"
+// completion and authorizes device logins on the first poll. Close it when done.
+func NewCodeWhisperer() *CodeWhisperer {
+	f := &CodeWhisperer{
+		completions:   []Completion{{Content: "ls -la"}},
+		polls:         map[string]int{},
+		clients:       map[string]string{},
+		accessTokens:  map[string]bool{},
+		refreshTokens: map[string]bool{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+// SetCompletions sets the completions returned for every subsequent request. They are paginated according
+// to the maximum number of results requested.
+func (f *CodeWhisperer) SetCompletions(completions ...Completion) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completions = completions
+}
+
+// SetPendingPolls sets how many times token polling answers that the device authorization is still pending
+// before the login is granted.
+func (f *CodeWhisperer) SetPendingPolls(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pendingPolls = n
+}
+
+// ExpireTokens invalidates every access token issued so far. Refresh tokens remain valid.
+func (f *CodeWhisperer) ExpireTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accessTokens = map[string]bool{}
+}
+
+// Requests returns the GenerateCompletions requests received so far.
+func (f *CodeWhisperer) Requests() []CodeWhispererRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CodeWhispererRequest(nil), f.requests...)
+}
+
+func (f *CodeWhisperer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		oidcError(w, http.StatusMethodNotAllowed, "InvalidRequestException", "method not allowed")
+		return
+	}
+	switch r.URL.Path {
+	case "/client/register":
+		f.registerClient(w, r)
+	case "/device_authorization":
+		f.startDeviceAuthorization(w, r)
+	case "/token":
+		f.createToken(w, r)
+	case "/":
+		if r.Header.Get("X-Amz-Target") != generateCompletionsTarget {
+			rpcError(w, http.StatusBadRequest, "UnknownOperationException", "unknown operation "+r.Header.Get("X-Amz-Target"))
+			return
+		}
+		f.generateCompletions(w, r)
+	default:
+		oidcError(w, http.StatusNotFound, "ResourceNotFoundException", "unknown url "+r.URL.Path)
+	}
+}
+
+func (f *CodeWhisperer) registerClient(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.issued++
+	id := fmt.Sprintf("client-%d", f.issued)
+	secret := fmt.Sprintf("secret-%d", f.issued)
+	f.clients[id] = secret
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"clientId":              id,
+		"clientSecret":          secret,
+		"clientIdIssuedAt":      time.Now().Unix(),
+		"clientSecretExpiresAt": time.Now().Add(90 * 24 * time.Hour).Unix(),
+	})
+}
+
+func (f *CodeWhisperer) startDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ClientID     string `json:"clientId"`
+		ClientSecret string `json:"clientSecret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		oidcError(w, http.StatusBadRequest, "InvalidRequestException", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.clients[request.ClientID] != request.ClientSecret {
+		oidcError(w, http.StatusUnauthorized, "InvalidClientException", "invalid client")
+		return
+	}
+	f.issued++
+	deviceCode := fmt.Sprintf("device-%d", f.issued)
+	f.polls[deviceCode] = 0
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deviceCode":              deviceCode,
+		"userCode":                "FAKE-CODE",
+		"verificationUri":         f.URL + "/verify",
+		"verificationUriComplete": f.URL + "/verify?code=FAKE-CODE",
+		"expiresIn":               600,
+		"interval":                0,
+	})
+}
+
+func (f *CodeWhisperer) createToken(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ClientID     string `json:"clientId"`
+		ClientSecret string `json:"clientSecret"`
+		GrantType    string `json:"grantType"`
+		DeviceCode   string `json:"deviceCode"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		oidcError(w, http.StatusBadRequest, "InvalidRequestException", err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.clients[request.ClientID] != request.ClientSecret {
+		oidcError(w, http.StatusUnauthorized, "InvalidClientException", "invalid client")
+		return
+	}
+	switch request.GrantType {
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		polls, ok := f.polls[request.DeviceCode]
+		if !ok {
+			oidcError(w, http.StatusBadRequest, "InvalidGrantException", "unknown device code")
+			return
+		}
+		if polls < f.pendingPolls {
+			f.polls[request.DeviceCode] = polls + 1
+			oidcError(w, http.StatusBadRequest, "AuthorizationPendingException", "authorization pending")
+			return
+		}
+		delete(f.polls, request.DeviceCode)
+	case "refresh_token":
+		if !f.refreshTokens[request.RefreshToken] {
+			oidcError(w, http.StatusBadRequest, "InvalidGrantException", "invalid refresh token")
+			return
+		}
+		delete(f.refreshTokens, request.RefreshToken)
+	default:
+		oidcError(w, http.StatusBadRequest, "UnsupportedGrantTypeException", "unsupported grant type "+request.GrantType)
+		return
+	}
+
+	f.issued++
+	accessToken := fmt.Sprintf("access-%d", f.issued)
+	refreshToken := fmt.Sprintf("refresh-%d", f.issued)
+	f.accessTokens[accessToken] = true
+	f.refreshTokens[refreshToken] = true
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+		"tokenType":    "Bearer",
+		"expiresIn":    3600,
+	})
+}
+
+func (f *CodeWhisperer) generateCompletions(w http.ResponseWriter, r *http.Request) {
+	var request CodeWhispererRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		rpcError(w, http.StatusBadRequest, "SerializationException", err.Error())
+		return
+	}
+	request.Token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	authorized := f.accessTokens[request.Token]
+	completions := append([]Completion(nil), f.completions...)
+	f.mu.Unlock()
+
+	if !authorized {
+		rpcError(w, http.StatusForbidden, "AccessDeniedException", "invalid bearer token")
+		return
+	}
+
+	switch f.next() {
+	case Throttle:
+		rpcError(w, http.StatusBadRequest, "ThrottlingException", "Rate exceeded")
+		return
+	case ExpiredToken:
+		f.mu.Lock()
+		delete(f.accessTokens, request.Token)
+		f.mu.Unlock()
+		rpcError(w, http.StatusForbidden, "AccessDeniedException", "token expired")
+		return
+	case MalformedJSON:
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		_, _ = fmt.Fprint(w, `{"completions": [}`)
+		return
+	case ServerError:
+		rpcError(w, http.StatusInternalServerError, "InternalServerException", "internal error")
+		return
+	}
+
+	start := 0
+	if request.NextToken != "" {
+		var err error
+		start, err = strconv.Atoi(request.NextToken)
+		if err != nil || start < 0 || start > len(completions) {
+			rpcError(w, http.StatusBadRequest, "ValidationException", "invalid next token")
+			return
+		}
+	}
+	pageSize := request.MaxResults
+	if pageSize <= 0 {
+		pageSize = 5
+	}
+	end := start + pageSize
+	if end > len(completions) {
+		end = len(completions)
+	}
+	response := map[string]interface{}{
+		"completions": completions[start:end],
+	}
+	if end < len(completions) {
+		response["nextToken"] = strconv.Itoa(end)
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// rpcError writes an error the way AWS JSON-RPC services do.
+func rpcError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"__type":  "com.amazon.coral.service#" + code,
+		"message": message,
+	})
+}
+
+// oidcError writes an error the way the SSO OIDC REST-JSON service does.
+func oidcError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("X-Amzn-Errortype", code)
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": message,
+	})
+}
@@ -0,0 +1,6 @@
+// Package fakes provides in-process fakes of the services witty engines talk to, so that engines can be
+// tested without network access or credentials.
+//
+// Each fake is an httptest.Server. Its behavior can be scripted: responses are configured with setters and
+// failures such as throttling, expired credentials or malformed responses are queued with FailNext.
+package fakes
@@ -0,0 +1,56 @@
+package fakes
+
+import "sync"
+
+// Failure is a scripted failure a fake answers a request with instead of a regular response.
+type Failure int
+
+const (
+	// Throttle answers with a rate limiting error.
+	Throttle Failure = iota + 1
+	// ExpiredToken answers as if the credentials presented by the caller had expired.
+	ExpiredToken
+	// MalformedJSON answers with a successful status and a body that is not valid JSON.
+	MalformedJSON
+	// ServerError answers with an internal server error.
+	ServerError
+)
+
+func (f Failure) String() string {
+	switch f {
+	case Throttle:
+		return "Throttle"
+	case ExpiredToken:
+		return "ExpiredToken"
+	case MalformedJSON:
+		return "MalformedJSON"
+	case ServerError:
+		return "ServerError"
+	}
+	return "None"
+}
+
+// script is a queue of failures to answer the next requests with.
+type script struct {
+	mu       sync.Mutex
+	failures []Failure
+}
+
+// FailNext queues failures, one per subsequent request, before regular responses resume.
+func (s *script) FailNext(failures ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failures...)
+}
+
+// next pops the next queued failure, or returns 0 when there is none.
+func (s *script) next() Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failures) == 0 {
+		return 0
+	}
+	f := s.failures[0]
+	s.failures = s.failures[1:]
+	return f
+}
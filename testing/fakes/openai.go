@@ -0,0 +1,204 @@
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenAIKey is the API key the OpenAI fake accepts by default.
+const OpenAIKey = "test-key"
+
+// OpenAI fakes the OpenAI completions and chat completions APIs.
+type OpenAI struct {
+	*httptest.Server
+	script
+
+	mu          sync.Mutex
+	apiKey      string
+	completions []string
+	requests    []OpenAIRequest
+}
+
+// OpenAIRequest is a request received by the OpenAI fake.
+type OpenAIRequest struct {
+	Path        string
+	Model       string        `json:"model"`
+	Prompt      string        `json:"prompt"`
+	Suffix      string        `json:"suffix"`
+	Messages    []ChatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens"`
+	Temperature float64       `json:"temperature"`
+	Stop        []string      `json:"stop"`
+}
+
+// ChatMessage is a message of a chat completions request or response.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// NewOpenAI starts an OpenAI fake that accepts OpenAIKey and answers every request with a single "ls -la"
+// completion. Close it when done.
+func NewOpenAI() *OpenAI {
+	f := &OpenAI{
+		apiKey:      OpenAIKey,
+		completions: []string{"ls -la"},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+// EnginesURL returns the base URL of the legacy engines API, as expected by codex.CompletionParameters.
+func (f *OpenAI) EnginesURL() string {
+	return f.URL + "/v1/engines"
+}
+
+// SetAPIKey changes the API key accepted by the fake.
+func (f *OpenAI) SetAPIKey(apiKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.apiKey = apiKey
+}
+
+// SetCompletions sets the choices returned for every subsequent request, in order.
+func (f *OpenAI) SetCompletions(texts ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.completions = texts
+}
+
+// Requests returns the requests received so far.
+func (f *OpenAI) Requests() []OpenAIRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]OpenAIRequest(nil), f.requests...)
+}
+
+func (f *OpenAI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		openAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", "method not allowed")
+		return
+	}
+
+	var request OpenAIRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "", "could not parse the JSON body: "+err.Error())
+		return
+	}
+	request.Path = r.URL.Path
+
+	chat := false
+	switch {
+	case r.URL.Path == "/v1/completions":
+	case r.URL.Path == "/v1/chat/completions":
+		chat = true
+	case strings.HasPrefix(r.URL.Path, "/v1/engines/") && strings.HasSuffix(r.URL.Path, "/completions"):
+		request.Model = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/engines/"), "/completions")
+	default:
+		openAIError(w, http.StatusNotFound, "invalid_request_error", "", "unknown url "+r.URL.Path)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, request)
+	apiKey := f.apiKey
+	completions := append([]string(nil), f.completions...)
+	f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+apiKey {
+		openAIError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided")
+		return
+	}
+
+	switch f.next() {
+	case Throttle:
+		w.Header().Set("Retry-After", "1")
+		openAIError(w, http.StatusTooManyRequests, "requests", "rate_limit_exceeded", "Rate limit reached")
+		return
+	case ExpiredToken:
+		openAIError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided")
+		return
+	case MalformedJSON:
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"choices": [}`)
+		return
+	case ServerError:
+		openAIError(w, http.StatusInternalServerError, "server_error", "", "The server had an error while processing your request")
+		return
+	}
+
+	if chat {
+		writeJSON(w, http.StatusOK, chatCompletion(request.Model, completions))
+		return
+	}
+	writeJSON(w, http.StatusOK, textCompletion(request.Model, completions))
+}
+
+func textCompletion(model string, completions []string) map[string]interface{} {
+	// Every choice is a single token. The top log probabilities list all the choices so that
+	// callers exploring alternatives get them all.
+	topLogProbs := map[string]float64{}
+	for i, text := range completions {
+		topLogProbs[text] = -0.1 * float64(i+1)
+	}
+	choices := make([]map[string]interface{}, 0, len(completions))
+	for i, text := range completions {
+		choices = append(choices, map[string]interface{}{
+			"text":  text,
+			"index": i,
+			"logprobs": map[string]interface{}{
+				"tokens":         []string{text},
+				"token_logprobs": []float64{-0.1 * float64(i+1)},
+				"top_logprobs":   []map[string]float64{topLogProbs},
+				"text_offset":    []float64{0},
+			},
+			"finish_reason": "stop",
+		})
+	}
+	return map[string]interface{}{
+		"id":      "cmpl-fake",
+		"object":  "text_completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": choices,
+	}
+}
+
+func chatCompletion(model string, completions []string) map[string]interface{} {
+	choices := make([]map[string]interface{}, 0, len(completions))
+	for i, text := range completions {
+		choices = append(choices, map[string]interface{}{
+			"index":         i,
+			"message":       ChatMessage{Role: "assistant", Content: text},
+			"finish_reason": "stop",
+		})
+	}
+	return map[string]interface{}{
+		"id":      "chatcmpl-fake",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": choices,
+	}
+}
+
+func openAIError(w http.ResponseWriter, status int, errorType, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errorType,
+			"code":    code,
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
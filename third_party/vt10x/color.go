@@ -0,0 +1,54 @@
+package vt10x
+
+// ANSI color values
+const (
+	Black Color = iota
+	Red
+	Green
+	Yellow
+	Blue
+	Magenta
+	Cyan
+	LightGrey
+	DarkGrey
+	LightRed
+	LightGreen
+	LightYellow
+	LightBlue
+	LightMagenta
+	LightCyan
+	White
+)
+
+// Default colors are potentially distinct to allow for special behavior.
+// For example, a transparent background. Otherwise, the simple case is to
+// map default colors to another color.
+const (
+	DefaultFG Color = 0xff80 + iota
+	DefaultBG
+)
+
+// Color maps to the ANSI colors [0, 16), the xterm colors [16, 256) and, with trueColor set,
+// to 24-bit RGB colors.
+type Color uint32
+
+// trueColor marks colors holding an RGB value in their lower 24 bits.
+const trueColor Color = 1 << 24
+
+// RGB returns the 24-bit color with the given red, green and blue components.
+func RGB(r, g, b uint8) Color {
+	return trueColor | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// RGB returns the red, green and blue components of a 24-bit color. ok is false for other colors.
+func (c Color) RGB() (r, g, b uint8, ok bool) {
+	if c&trueColor == 0 {
+		return 0, 0, 0, false
+	}
+	return uint8(c >> 16), uint8(c >> 8), uint8(c), true
+}
+
+// ANSI returns true if Color is within [0, 16).
+func (c Color) ANSI() bool {
+	return (c < 16)
+}
@@ -0,0 +1,9 @@
+/*
+Package terminal is a vt10x terminal emulation backend, influenced
+largely by st, rxvt, xterm, and iTerm as reference. Use it for terminal
+muxing, a terminal emulation frontend, or wherever else you need
+terminal emulation.
+
+In development, but very usable.
+*/
+package vt10x
@@ -0,0 +1,20 @@
+module github.com/ActiveState/vt10x
+
+go 1.13
+
+require (
+	github.com/Netflix/go-expect v0.0.0-20180615182759-c93bf25de8e8
+	github.com/autarch/testify v1.2.2
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/gdamore/encoding v0.0.0-20151215212835-b23993cbb635 // indirect
+	github.com/gdamore/tcell v1.0.1-0.20180608172421-b3cebc399d6f
+	github.com/hinshun/vt10x v0.0.0-20180809195222-d55458df857c
+	github.com/kr/pty v1.1.1
+	github.com/lucasb-eyer/go-colorful v0.0.0-20180526135729-345fbb3dbcdb // indirect
+	github.com/mattn/go-runewidth v0.0.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/smartystreets/goconvey v1.6.4 // indirect
+	github.com/stretchr/testify v1.2.1
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	gopkg.in/DATA-DOG/go-sqlmock.v1 v1.3.0 // indirect
+)